@@ -28,6 +28,8 @@ type response struct {
 	writer http.ResponseWriter
 	status int
 	size   int64
+	//the funcs called before the header is written, see Context.BeforeWrite
+	before []func()
 }
 
 //http.ResponseWriter implements Flusher
@@ -41,7 +43,12 @@ func (w *response) Flush() {
 //panic if type assert failed
 //get the connection under request
 func (w *response) Hijack() (conn net.Conn, io *bufio.ReadWriter, err error) {
-	return w.writer.(http.Hijacker).Hijack()
+	conn, io, err = w.writer.(http.Hijacker).Hijack()
+	if err == nil && w.size == nowriten {
+		//the connection has been taken over, nothing should be written any more
+		w.size = 0
+	}
+	return
 }
 
 //http.ResponseWriter implements CloseNotifier
@@ -63,6 +70,7 @@ func (w *response) SetHeader(key, value string) {
 // a response header to the client
 func (w *response) Write(bytes []byte) (n int, err error) {
 	if w.size == nowriten {
+		w.runBefore()
 		w.size = 0
 		w.writer.WriteHeader(w.status)
 	}
@@ -76,12 +84,29 @@ func (w *response) Write(bytes []byte) (n int, err error) {
 //WriteHeader set the response status code
 func (w *response) WriteHeader(statusCode int) {
 	if w.size == nowriten {
+		w.runBefore()
 		w.status = statusCode
 		w.size = 0
 		w.writer.WriteHeader(statusCode)
 	}
 }
 
+//call the before funcs in the reverse order of registering, they are called only once
+func (w *response) runBefore() {
+	before := w.before
+	w.before = nil
+	for i := len(before) - 1; i >= 0; i-- {
+		before[i]()
+	}
+}
+
+//send the status code to the client if nothing has been written
+func (w *response) writeHeaderNow() {
+	if w.size == nowriten {
+		w.WriteHeader(w.status)
+	}
+}
+
 func (w *response) SetStatus(status int) {
 	if w.size == nowriten {
 		w.status = status
@@ -103,13 +128,14 @@ func (w *response) Size() int64 {
 func (w *response) reset(writer http.ResponseWriter) {
 	w.writer = writer
 	w.size = nowriten
+	w.before = nil
 	//TODO:replace http code from http to goil
 	w.status = http.StatusOK
 }
 
 func (w *response) clear() {
 	w.writer = nil
-
+	w.before = nil
 }
 
 func newResponse() Response {
@@ -130,3 +156,43 @@ func bodyAllowedForStatus(status int) bool {
 	}
 	return true
 }
+
+//copiedResponse is used by the copied context,
+//it keeps a snapshot of the response and can't write anything
+type copiedResponse struct {
+	header http.Header
+	status int
+	size   int64
+}
+
+func (w *copiedResponse) Flush() {}
+
+func (w *copiedResponse) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, ResponseCopied
+}
+
+func (w *copiedResponse) CloseNotify() <-chan bool {
+	return make(chan bool)
+}
+
+func (w *copiedResponse) Header() http.Header {
+	return w.header
+}
+
+func (w *copiedResponse) SetHeader(key, value string) {}
+
+func (w *copiedResponse) Write(bytes []byte) (int, error) {
+	return 0, ResponseCopied
+}
+
+func (w *copiedResponse) WriteHeader(statusCode int) {}
+
+func (w *copiedResponse) SetStatus(status int) {}
+
+func (w *copiedResponse) Status() int {
+	return w.status
+}
+
+func (w *copiedResponse) Size() int64 {
+	return w.size
+}
@@ -5,7 +5,6 @@ import (
 	"encoding/xml"
 	"errors"
 	"fmt"
-	"goil/logger"
 	"io/ioutil"
 	"mime"
 	"mime/multipart"
@@ -15,9 +14,9 @@ import (
 	"time"
 )
 
-func bindValue(src string, dv reflect.Value, dt reflect.Type, tag reflect.StructTag) error {
+func bindValue(conf *Config, src string, dv reflect.Value, dt reflect.Type, tag reflect.StructTag) error {
 	conv := tag.Get(CONVERT)
-	if convFunc, exists := convertFunc[conv]; conv != "" && exists {
+	if convFunc, exists := conf.convert(conv); conv != "" && exists {
 		if !dv.CanSet() {
 			return nil
 		}
@@ -36,28 +35,28 @@ func bindValue(src string, dv reflect.Value, dt reflect.Type, tag reflect.Struct
 	switch dt.Kind() {
 	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
 		conv = "_a2i"
-		v, err = convertFunc[conv](src, dt)
+		v, err = conf.mustConvert(conv)(src, dt)
 		if err != nil {
 			break
 		}
 		dv.SetInt(v.(int64))
 	case reflect.Uint, reflect.Uintptr, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		conv = "_a2u"
-		v, err = convertFunc[conv](src, dt)
+		v, err = conf.mustConvert(conv)(src, dt)
 		if err != nil {
 			break
 		}
 		dv.SetUint(v.(uint64))
 	case reflect.Bool:
 		conv = "_a2b"
-		v, err = convertFunc[conv](src, dt)
+		v, err = conf.mustConvert(conv)(src, dt)
 		if err != nil {
 			break
 		}
 		dv.SetBool(v.(bool))
 	case reflect.Float32, reflect.Float64:
 		conv = "_a2f"
-		v, err = convertFunc[conv](src, dt)
+		v, err = conf.mustConvert(conv)(src, dt)
 		if err != nil {
 			break
 		}
@@ -75,7 +74,7 @@ type File struct {
 	ContentType string
 }
 
-func bindFile(fh *multipart.FileHeader, dv reflect.Value, dt reflect.Type) error {
+func bindFile(conf *Config, fh *multipart.FileHeader, dv reflect.Value, dt reflect.Type) error {
 
 	if !dv.CanSet() {
 		return nil
@@ -105,7 +104,7 @@ func bindFile(fh *multipart.FileHeader, dv reflect.Value, dt reflect.Type) error
 		if file, ok := fd.(*os.File); ok {
 			dv.Set(valueOf(file))
 		} else {
-			logger.Warnf("for reading the uploaded file: %s,please use the type %s replace %s", fh.Filename, "multipart.File", "*os.File")
+			conf.logger().Warnf("for reading the uploaded file: %s,please use the type %s replace %s", fh.Filename, "multipart.File", "*os.File")
 		}
 
 	case *File:
@@ -139,7 +138,7 @@ func bindFile(fh *multipart.FileHeader, dv reflect.Value, dt reflect.Type) error
 		if file, ok := fd.(*os.File); ok {
 			dv.Set(valueOf(file).Elem())
 		} else {
-			logger.Warnf("for reading the uploaded file: %s,please use the type %s replace %s", fh.Filename, "multipart.File", "os.File")
+			conf.logger().Warnf("for reading the uploaded file: %s,please use the type %s replace %s", fh.Filename, "multipart.File", "os.File")
 		}
 
 	case File:
@@ -181,7 +180,7 @@ const (
 	FILE      = "file"
 )
 
-func bindPathParams(params Params, iface interface{}) (err error) {
+func bindPathParams(conf *Config, params Params, iface interface{}) (err error) {
 	if len(params) == 0 {
 		return
 	}
@@ -203,7 +202,7 @@ func bindPathParams(params Params, iface interface{}) (err error) {
 		if IsStructReally(dt) {
 			dv, dt = dereference(dv, dt)
 			//need the pointer type interface
-			bindPathParams(params, dv.Addr().Interface())
+			bindPathParams(conf, params, dv.Addr().Interface())
 			continue
 		}
 
@@ -214,7 +213,7 @@ func bindPathParams(params Params, iface interface{}) (err error) {
 			continue
 		}
 		dv, dt = dereference(dv, dt)
-		err = bindValue(pVal, dv, dt, fTyp.Tag)
+		err = bindValue(conf, pVal, dv, dt, fTyp.Tag)
 		if err != nil {
 			return
 		}
@@ -223,7 +222,7 @@ func bindPathParams(params Params, iface interface{}) (err error) {
 	return
 }
 
-func bindQueryParams(request *http.Request, iface interface{}) (err error) {
+func bindQueryParams(conf *Config, request *http.Request, iface interface{}) (err error) {
 	values := request.URL.Query()
 	if len(values) == 0 {
 		return nil
@@ -243,7 +242,7 @@ func bindQueryParams(request *http.Request, iface interface{}) (err error) {
 
 		if IsStructReally(dt) {
 			dv, dt = dereference(dv, dt)
-			bindQueryParams(request, dv.Addr().Interface())
+			bindQueryParams(conf, request, dv.Addr().Interface())
 			continue
 		}
 		tag := fTyp.Tag
@@ -261,7 +260,7 @@ func bindQueryParams(request *http.Request, iface interface{}) (err error) {
 			err = bindValues(pVal, dv, dt)
 		} else {
 			dv, dt = dereference(dv, dt)
-			err = bindValue(pVal[0], dv, dt, fTyp.Tag)
+			err = bindValue(conf, pVal[0], dv, dt, fTyp.Tag)
 		}
 		if err != nil {
 			return
@@ -270,28 +269,63 @@ func bindQueryParams(request *http.Request, iface interface{}) (err error) {
 	return
 }
 
+//the max bytes of the multipart form stored in memory by default
 const DEFAULT_SIZE = 4 * 1024 * 1024
 
-func bindFormParams(req *http.Request, iface interface{}) (err error) {
+//bodyError converts the error of reading the body over the limit to RequestTooLarge
+func bodyError(err error) error {
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		return RequestTooLarge.copy()
+	}
+	return err
+}
+
+//parseMultipart parses the multipart form once, the body over the memory and
+//the disk limit of the multipart responds RequestTooLarge
+func (conf *Config) parseMultipart(req *http.Request) error {
+	if req.MultipartForm != nil {
+		return nil
+	}
+	memory := conf.multipartMemory()
+	if conf != nil && conf.MultipartDiskLimit > 0 {
+		req.Body = http.MaxBytesReader(nil, req.Body, memory+conf.MultipartDiskLimit)
+	}
+	err := req.ParseMultipartForm(memory)
+	if err != nil {
+		//the multipart reader may report the truncated part instead of the body over the limit,
+		//the limited body returns its error again
+		var mbe *http.MaxBytesError
+		if _, rerr := req.Body.Read(nil); errors.As(rerr, &mbe) {
+			return RequestTooLarge.copy()
+		}
+	}
+	return bodyError(err)
+}
+
+//bindFormParams binds the form values by the converts of the app
+func (conf *Config) bindFormParams(req *http.Request, iface interface{}) (err error) {
 	err = req.ParseForm()
 	if err != nil {
-		return
+		return bodyError(err)
 	}
 	contentType := req.Header.Get("Content-Type")
 	if contentType != "" {
 		ct, _, err := mime.ParseMediaType(contentType)
 		if ct == MIME_MULT_POST && err == nil {
 			st := time.Now()
-			req.ParseMultipartForm(DEFAULT_SIZE)
-			logger.Infof("parse multipart form:%v", time.Now().Sub(st))
+			err = conf.parseMultipart(req)
+			if err != nil && err != http.ErrNotMultipart {
+				return err
+			}
+			conf.logger().Infof("parse multipart form:%v", time.Now().Sub(st))
 		}
 	}
 	noFile := req.MultipartForm == nil || req.MultipartForm.File == nil
-	bindFormParams2(req, noFile, iface)
-	return nil
+	return bindFormParams2(conf, req, noFile, iface)
 }
 
-func bindFormParams2(req *http.Request, noFile bool, iface interface{}) (err error) {
+func bindFormParams2(conf *Config, req *http.Request, noFile bool, iface interface{}) (err error) {
 	val := valueOf(iface)
 	typ := typeOf(iface)
 	val, typ = dereference(val, typ)
@@ -313,7 +347,7 @@ func bindFormParams2(req *http.Request, noFile bool, iface interface{}) (err err
 				continue
 			}
 			if pVal, exist := req.MultipartForm.File[fileKey]; exist && len(pVal) > 0 {
-				err = bindFile(pVal[0], dv, dt)
+				err = bindFile(conf, pVal[0], dv, dt)
 				if err != nil {
 					return
 				}
@@ -324,7 +358,7 @@ func bindFormParams2(req *http.Request, noFile bool, iface interface{}) (err err
 		if IsStructReally(dt) {
 			dv, dt = dereference(dv, dt)
 			//support the nested struct
-			bindFormParams2(req, noFile, dv.Addr().Interface())
+			bindFormParams2(conf, req, noFile, dv.Addr().Interface())
 			continue
 		}
 
@@ -341,7 +375,7 @@ func bindFormParams2(req *http.Request, noFile bool, iface interface{}) (err err
 			err = bindValues(pVal, dv, dt)
 		} else {
 			dv, dt = dereference(dv, dt)
-			err = bindValue(pVal[0], dv, dt, fTyp.Tag)
+			err = bindValue(conf, pVal[0], dv, dt, fTyp.Tag)
 		}
 		if err != nil {
 			return
@@ -373,17 +407,17 @@ func bindXml(req *http.Request, iface interface{}) (err error) {
 //change the params to request
 type ParamsBinder func(req *http.Request, iface interface{}) error
 
+//the form values are bound by Config.bindFormParams if no binder is registered
 var paramsHandlers = map[string]ParamsBinder{
-	MIME_JSON:      bindJSON,
-	MIME_POST:      bindFormParams,
-	MIME_MULT_POST: bindFormParams,
-	MIME_XML:       bindXml,
+	MIME_JSON: bindJSON,
+	MIME_XML:  bindXml,
 }
 
+//RegisterParamsHandler registers the default binder for the apps, see WithParamsHandler
 func RegisterParamsHandler(tag string, handler ParamsBinder) {
-	guard.execSafely(func() {
-		paramsHandlers[tag] = handler
-	})
+	defaultsMu.Lock()
+	paramsHandlers[tag] = handler
+	defaultsMu.Unlock()
 }
 
 func bind(c *Context, iface interface{}) (err error) {
@@ -396,13 +430,14 @@ func bind(c *Context, iface interface{}) (err error) {
 	}
 
 	//1.bind path params
-	err = bindPathParams(c.params, iface)
+	conf := c.config()
+	err = bindPathParams(conf, c.params, iface)
 	if err != nil {
 		return err
 	}
 
 	//2.bind url params
-	err = bindQueryParams(c.Request, iface)
+	err = bindQueryParams(conf, c.Request, iface)
 	if err != nil {
 		return err
 	}
@@ -416,9 +451,9 @@ func bind(c *Context, iface interface{}) (err error) {
 		return err
 	}
 	//3.bind body params
-	if handler, exist := paramsHandlers[mt]; exist {
+	if handler, exist := conf.binder(mt); exist {
 		err = handler(c.Request, iface)
-		return err
+		return bodyError(err)
 	}
 	return UnsupportMimeType
 }
@@ -28,7 +28,7 @@ func TestBinding(t *testing.T) {
 		},
 	}
 	test := &Test{}
-	bindPathParams(c.params, test)
+	bindPathParams(nil, c.params, test)
 	t.Errorf("%d %f %s", *test.I, *test.F, test.S)
 
 }
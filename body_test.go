@@ -0,0 +1,114 @@
+package goil
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBodyLimit(t *testing.T) {
+	type Params struct {
+		Name string `json:"name" form:"name"`
+	}
+	var files []string
+	var bindErr error
+	app := New(WithBodyLimit(32), WithMultipart(16, 1024))
+	bindName := func(c *Context) {
+		p := Params{}
+		if err := c.Bind(&p); err != nil {
+			bindErr = err
+			c.Error(err)
+			return
+		}
+		if c.Request.MultipartForm != nil {
+			for _, fhs := range c.Request.MultipartForm.File {
+				f, _ := fhs[0].Open()
+				if file, ok := f.(*os.File); ok {
+					files = append(files, file.Name())
+				}
+				f.Close()
+			}
+		}
+		c.Text(p.Name)
+	}
+	app.POST("/users", bindName)
+	app.POST("/uploads", bindName).With(BodyLimit(4096))
+	dest := t.TempDir() + "/a.txt"
+	app.POST("/files", func(c *Context) {
+		if err := c.SaveFile("file", dest); err != nil {
+			c.Error(err)
+			return
+		}
+		c.Text("saved")
+	}).With(BodyLimit(4096))
+
+	post := func(path, contentType string, body []byte) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(POST, path, bytes.NewReader(body))
+		r.Header.Set(CONTENT_TYPE, contentType)
+		app.ServeHTTP(w, r)
+		return w
+	}
+	multipartBody := func(size int) (string, []byte) {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		mw.WriteField("name", "goil")
+		fw, _ := mw.CreateFormFile("file", "a.txt")
+		fw.Write([]byte(strings.Repeat("a", size)))
+		mw.Close()
+		return mw.FormDataContentType(), buf.Bytes()
+	}
+
+	if w := post("/users", MIME_JSON, []byte(`{"name":"goil"}`)); w.Code != http.StatusOK || w.Body.String() != "goil" {
+		t.Errorf("POST /users = %d %q", w.Code, w.Body.String())
+	}
+	if w := post("/users", MIME_JSON, []byte(`{"name":"`+strings.Repeat("a", 64)+`"}`)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("POST /users = %d; want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+	//the error is a copy of the shared one
+	if bindErr == error(RequestTooLarge) || !errors.Is(bindErr, RequestTooLarge) {
+		t.Errorf("the error = %#v", bindErr)
+	}
+	ct, body := multipartBody(512)
+	if w := post("/uploads", ct, body); w.Code != http.StatusOK || w.Body.String() != "goil" {
+		t.Errorf("POST /uploads = %d %q", w.Code, w.Body.String())
+	}
+	//the file is stored on disk and removed after the request
+	if len(files) != 1 {
+		t.Fatalf("the file should be stored in the temp file")
+	}
+	if _, err := os.Stat(files[0]); !os.IsNotExist(err) {
+		t.Errorf("the temp file %s isn't removed", files[0])
+	}
+	//over the disk limit of the multipart
+	ct, body = multipartBody(2048)
+	if w := post("/uploads", ct, body); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("POST /uploads = %d; want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+	//the limit within the part headers
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("name", strings.Repeat("a", 856))
+	fw, _ := mw.CreateFormFile("file", "a.txt")
+	fw.Write([]byte(strings.Repeat("a", 2048)))
+	mw.Close()
+	if w := post("/uploads", mw.FormDataContentType(), buf.Bytes()); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("POST /uploads = %d; want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+	//SaveFile is limited too
+	if w := post("/files", ct, body); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("POST /files = %d; want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Errorf("the file over the limit is saved")
+	}
+	ct, body = multipartBody(512)
+	if w := post("/files", ct, body); w.Code != http.StatusOK || w.Body.String() != "saved" {
+		t.Errorf("POST /files = %d %q", w.Code, w.Body.String())
+	}
+}
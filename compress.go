@@ -0,0 +1,472 @@
+package goil
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	ENCODING_GZIP    = "gzip"
+	ENCODING_DEFLATE = "deflate"
+)
+
+//the body shorter than it isn't compressed by default
+const DEFAULT_COMPRESS_MIN_LENGTH = 1024
+
+//the content types which have been compressed, the type ends with '/' matches all the sub types
+var DefaultCompressExcludedTypes = []string{
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/webp",
+	"video/",
+	"audio/",
+	"font/woff",
+	"font/woff2",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+	"application/pdf",
+}
+
+//CompressConfig configures the middleware created by CompressWithConfig
+type CompressConfig struct {
+	//the compression level, GZIP_DefaultCompression is used if it is zero
+	Level int
+	//the body shorter than it isn't compressed, DEFAULT_COMPRESS_MIN_LENGTH is used if it is zero
+	MinLength int
+	//the encodings in order of preference, gzip and deflate are used if it is nil
+	Encodings []string
+	//the content types which aren't compressed, DefaultCompressExcludedTypes is used if it is nil
+	ExcludedTypes []string
+}
+
+//Compress compresses the response by gzip or deflate negotiated by the Accept-Encoding
+func Compress() HandlerFunc {
+	return CompressWithConfig(CompressConfig{})
+}
+
+//EnableGzip compresses the response by gzip with the level
+func EnableGzip(level int) HandlerFunc {
+	if level == GZIP_NoCompression {
+		return func(c *Context) {
+			c.Next()
+		}
+	}
+	return CompressWithConfig(CompressConfig{
+		Level:     level,
+		Encodings: []string{ENCODING_GZIP},
+	})
+}
+
+//CompressWithConfig returns the compression middleware with the config,
+//the writer is pooled and kept for the whole response, so the streaming and flushing responses work
+func CompressWithConfig(conf CompressConfig) HandlerFunc {
+	if conf.Level == 0 {
+		conf.Level = GZIP_DefaultCompression
+	}
+	if conf.Level < GZIP_HuffmanOnly || conf.Level > GZIP_BestCompression {
+		panic(fmt.Sprintf("compress: invalid compression level: %d", conf.Level))
+	}
+	if conf.MinLength == 0 {
+		conf.MinLength = DEFAULT_COMPRESS_MIN_LENGTH
+	}
+	if conf.Encodings == nil {
+		conf.Encodings = []string{ENCODING_GZIP, ENCODING_DEFLATE}
+	}
+	if conf.ExcludedTypes == nil {
+		conf.ExcludedTypes = DefaultCompressExcludedTypes
+	}
+	pools := make(map[string]*sync.Pool, len(conf.Encodings))
+	for _, encoding := range conf.Encodings {
+		level := conf.Level
+		switch encoding {
+		case ENCODING_GZIP:
+			pools[encoding] = &sync.Pool{
+				New: func() interface{} {
+					w, _ := gzip.NewWriterLevel(nil, level)
+					return w
+				},
+			}
+		case ENCODING_DEFLATE:
+			pools[encoding] = &sync.Pool{
+				New: func() interface{} {
+					w, _ := flate.NewWriter(nil, level)
+					return w
+				},
+			}
+		default:
+			panic(fmt.Sprintf("compress: unsupported encoding: %s", encoding))
+		}
+	}
+
+	return func(c *Context) {
+		//the response varies by the Accept-Encoding whether it is compressed or not
+		addVary(c.Response.Header(), ACCEPT_ENCODING)
+		if c.Request.Method == HEAD {
+			c.Next()
+			return
+		}
+		encoding := negotiateEncoding(c.Header(ACCEPT_ENCODING), conf.Encodings)
+		if encoding == "" {
+			c.Next()
+			return
+		}
+		cw := &compressResponse{
+			Response: c.Response,
+			conf:     &conf,
+			encoding: encoding,
+			pool:     pools[encoding],
+		}
+		c.Response = cw
+		defer func() {
+			cw.close()
+			c.Response = cw.Response
+		}()
+		c.Next()
+	}
+}
+
+//the writer compressing the body, it is *gzip.Writer or *flate.Writer
+type compressWriter interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+//compressResponse buffers the body until the MinLength is reached or it is flushed,
+//then it decides whether to compress the body by the status and the content type
+type compressResponse struct {
+	Response
+	conf     *CompressConfig
+	encoding string
+	pool     *sync.Pool
+
+	buf         []byte
+	decided     bool
+	wroteHeader bool
+	//not nil if the body is compressed
+	writer compressWriter
+}
+
+func (w *compressResponse) WriteHeader(code int) {
+	if w.decided {
+		w.Response.WriteHeader(code)
+		return
+	}
+	//delay the header until the body is decided to be compressed or not
+	w.Response.SetStatus(code)
+	w.wroteHeader = true
+}
+
+func (w *compressResponse) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.conf.MinLength {
+			return len(b), nil
+		}
+		if err := w.decide(false); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if w.writer != nil {
+		return w.writer.Write(b)
+	}
+	return w.Response.Write(b)
+}
+
+//decide writes the header and the buffered body,
+//the MinLength is ignored when flushing, so the streaming responses are compressed by the content type
+func (w *compressResponse) decide(flushing bool) error {
+	w.decided = true
+	if w.compressible(flushing) {
+		header := w.Header()
+		header.Set(CONTENT_ENCODING, w.encoding)
+		header.Del(CONTENT_LENGTH)
+		w.writer = w.pool.Get().(compressWriter)
+		w.writer.Reset(w.Response)
+	}
+	w.Response.WriteHeader(w.Response.Status())
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.writer != nil {
+		_, err = w.writer.Write(buf)
+	} else {
+		_, err = w.Response.Write(buf)
+	}
+	return err
+}
+
+func (w *compressResponse) compressible(flushing bool) bool {
+	status := w.Response.Status()
+	if !flushing && len(w.buf) < w.conf.MinLength || !bodyAllowedForStatus(status) || status == http.StatusNotModified {
+		return false
+	}
+	header := w.Header()
+	//the body has been encoded by the handler
+	if header.Get(CONTENT_ENCODING) != "" {
+		return false
+	}
+	contentType := header.Get(CONTENT_TYPE)
+	if contentType == "" {
+		contentType = http.DetectContentType(w.buf)
+		header.Set(CONTENT_TYPE, contentType)
+	}
+	if idx := strings.IndexByte(contentType, ';'); idx >= 0 {
+		contentType = contentType[:idx]
+	}
+	contentType = strings.TrimSpace(strings.ToLower(contentType))
+	for _, excluded := range w.conf.ExcludedTypes {
+		if strings.HasSuffix(excluded, "/") && strings.HasPrefix(contentType, excluded) || contentType == excluded {
+			return false
+		}
+	}
+	return true
+}
+
+//Flush sends the buffered body, and flushes the compressed data.
+//the response flushed firstly is compressed whatever the length is, such as the server-sent events
+func (w *compressResponse) Flush() {
+	if !w.decided {
+		w.decide(true)
+	}
+	if w.writer != nil {
+		w.writer.Flush()
+	}
+	w.Response.Flush()
+}
+
+//Hijack takes over the connection, the body isn't compressed any more
+func (w *compressResponse) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.decided = true
+	w.buf = nil
+	return w.Response.Hijack()
+}
+
+func (w *compressResponse) Size() int64 {
+	if !w.decided && (w.wroteHeader || len(w.buf) > 0) {
+		return int64(len(w.buf))
+	}
+	return w.Response.Size()
+}
+
+//close writes the rest body and releases the writer
+func (w *compressResponse) close() {
+	if !w.decided {
+		if !w.wroteHeader && len(w.buf) == 0 {
+			//nothing is written, leave the response to the others such as the error handler
+			return
+		}
+		if len(w.buf) > 0 {
+			w.Header().Set(CONTENT_LENGTH, strconv.Itoa(len(w.buf)))
+		}
+		w.decide(false)
+	}
+	if w.writer != nil {
+		w.writer.Close()
+		w.writer.Reset(nil)
+		w.pool.Put(w.writer)
+		w.writer = nil
+	}
+}
+
+//negotiateEncoding returns the encoding of the offers accepted by the Accept-Encoding with the highest quality
+func negotiateEncoding(accept string, offers []string) string {
+	if accept == "" {
+		return ""
+	}
+	best, bestQ := "", 0.0
+	wildcard := -1.0
+	qs := make(map[string]float64)
+	for _, part := range strings.Split(accept, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, q := part, 1.0
+		if idx := strings.IndexByte(part, ';'); idx >= 0 {
+			name = strings.TrimSpace(part[:idx])
+			param := strings.TrimSpace(part[idx+1:])
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		name = strings.ToLower(name)
+		if name == "*" {
+			wildcard = q
+			continue
+		}
+		qs[name] = q
+	}
+	for _, offer := range offers {
+		q, exists := qs[offer]
+		if !exists {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+//addVary adds the value to the Vary header if it isn't there
+func addVary(header http.Header, value string) {
+	for _, v := range header[VARY] {
+		for _, item := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), value) {
+				return
+			}
+		}
+	}
+	header.Add(VARY, value)
+}
+
+//the max bytes of the decompressed request body by default
+const DEFAULT_DECOMPRESS_LIMIT = 10 << 20
+
+//RequestTooLarge is returned when reading the request body over the limit,
+//the error returned is a copy which can be checked by errors.Is
+var RequestTooLarge = NewHTTPError(http.StatusRequestEntityTooLarge, "the request body is too large.")
+
+//DecompressConfig configures the middleware created by DecompressWithConfig
+type DecompressConfig struct {
+	//the max bytes of the decompressed body, DEFAULT_DECOMPRESS_LIMIT is used if it is zero,
+	//reading over the limit returns RequestTooLarge
+	Limit int64
+}
+
+//Decompress inflates the request body encoded by gzip or deflate, so the binders read the raw body
+func Decompress() HandlerFunc {
+	return DecompressWithConfig(DecompressConfig{})
+}
+
+var gzipReaderPool sync.Pool
+
+//DecompressWithConfig returns the decompression middleware with the config
+func DecompressWithConfig(conf DecompressConfig) HandlerFunc {
+	if conf.Limit <= 0 {
+		conf.Limit = DEFAULT_DECOMPRESS_LIMIT
+	}
+	return func(c *Context) {
+		req := c.Request
+		encoding := strings.ToLower(strings.TrimSpace(req.Header.Get(CONTENT_ENCODING)))
+		if encoding == "" || encoding == "identity" || req.Body == nil || req.Body == http.NoBody {
+			c.Next()
+			return
+		}
+		body := &decompressedBody{
+			body:  req.Body,
+			limit: conf.Limit,
+		}
+		switch encoding {
+		case ENCODING_GZIP, "x-gzip":
+			zr, _ := gzipReaderPool.Get().(*gzip.Reader)
+			var err error
+			if zr == nil {
+				zr, err = gzip.NewReader(req.Body)
+			} else {
+				err = zr.Reset(req.Body)
+			}
+			if err != nil {
+				c.AbortWithError(NewHTTPError(http.StatusBadRequest).SetInternal(err))
+				return
+			}
+			body.reader = zr
+			body.release = func() {
+				zr.Close()
+				gzipReaderPool.Put(zr)
+			}
+		case ENCODING_DEFLATE:
+			reader, err := deflateReader(req.Body)
+			if err != nil {
+				c.AbortWithError(NewHTTPError(http.StatusBadRequest).SetInternal(err))
+				return
+			}
+			body.reader = reader
+			body.release = func() {
+				reader.Close()
+			}
+		default:
+			c.AbortWithError(NewHTTPError(http.StatusUnsupportedMediaType, "unsupported content encoding: "+encoding))
+			return
+		}
+		req.Header.Del(CONTENT_ENCODING)
+		req.Header.Del(CONTENT_LENGTH)
+		req.ContentLength = -1
+		req.Body = body
+		defer body.Close()
+		c.Next()
+	}
+}
+
+//deflateReader reads the zlib stream, or the raw deflate stream sent by some clients
+func deflateReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	//the zlib header: CM is 8 and the check bits
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+//decompressedBody limits the bytes read from the decompressed body
+type decompressedBody struct {
+	body    io.ReadCloser
+	reader  io.Reader
+	limit   int64
+	read    int64
+	release func()
+	closed  bool
+}
+
+func (b *decompressedBody) Read(p []byte) (n int, err error) {
+	if b.closed {
+		return 0, http.ErrBodyReadAfterClose
+	}
+	if b.read >= b.limit {
+		//check whether there is more data
+		var one [1]byte
+		if n, _ := b.reader.Read(one[:]); n > 0 {
+			return 0, RequestTooLarge.copy()
+		}
+		return 0, io.EOF
+	}
+	if rest := b.limit - b.read; int64(len(p)) > rest {
+		p = p[:rest]
+	}
+	n, err = b.reader.Read(p)
+	b.read += int64(n)
+	return
+}
+
+func (b *decompressedBody) Close() error {
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	b.release()
+	return b.body.Close()
+}
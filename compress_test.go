@@ -0,0 +1,192 @@
+package goil
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat("goil ", 1000)
+	app := New()
+	app.Use(Compress())
+	app.GET("/large", func(c *Context) {
+		c.Text(large)
+	})
+	app.GET("/small", func(c *Context) {
+		c.Text("small")
+	})
+	app.GET("/png", func(c *Context) {
+		c.Body(MIME_PNG, []byte(large))
+	})
+	app.GET("/empty", func(c *Context) {
+		c.Status(http.StatusNoContent)
+	})
+	app.GET("/stream", func(c *Context) {
+		c.Text(large)
+		c.Response.Flush()
+		c.Text(large)
+	})
+	app.GET("/events", func(c *Context) {
+		for i := 0; i < 3; i++ {
+			c.Body("text/event-stream", []byte("data: tick\n\n"))
+			c.Response.Flush()
+		}
+	})
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(GET, path, nil)
+		if accept != "" {
+			r.Header.Set(ACCEPT_ENCODING, accept)
+		}
+		app.ServeHTTP(w, r)
+		return w
+	}
+
+	w := get("/large", "gzip, deflate")
+	if w.Header().Get(CONTENT_ENCODING) != ENCODING_GZIP || w.Header().Get(VARY) != ACCEPT_ENCODING {
+		t.Fatalf("GET /large headers = %v", w.Header())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr.Multistream(false)
+	if body, _ := ioutil.ReadAll(zr); string(body) != large {
+		t.Errorf("GET /large body isn't gzipped correctly")
+	}
+
+	w = get("/large", "gzip;q=0.5, deflate")
+	if w.Header().Get(CONTENT_ENCODING) != ENCODING_DEFLATE {
+		t.Errorf("GET /large encoding = %q; want deflate", w.Header().Get(CONTENT_ENCODING))
+	}
+	if body, _ := ioutil.ReadAll(flate.NewReader(w.Body)); string(body) != large {
+		t.Errorf("GET /large body isn't deflated correctly")
+	}
+
+	//the flushed response is a single gzip member
+	w = get("/stream", "gzip")
+	zr, _ = gzip.NewReader(w.Body)
+	zr.Multistream(false)
+	if body, _ := ioutil.ReadAll(zr); string(body) != large+large {
+		t.Errorf("GET /stream body isn't gzipped correctly")
+	}
+	if rest, _ := ioutil.ReadAll(w.Body); len(rest) != 0 {
+		t.Errorf("GET /stream has %d bytes after the gzip member", len(rest))
+	}
+
+	//the small chunks flushed are compressed
+	w = get("/events", "gzip")
+	if w.Header().Get(CONTENT_ENCODING) != ENCODING_GZIP {
+		t.Fatalf("GET /events headers = %v", w.Header())
+	}
+	zr, _ = gzip.NewReader(w.Body)
+	if body, _ := ioutil.ReadAll(zr); string(body) != strings.Repeat("data: tick\n\n", 3) {
+		t.Errorf("GET /events body = %q", body)
+	}
+
+	cases := []struct {
+		path   string
+		accept string
+		code   int
+		body   string
+	}{
+		{"/large", "", http.StatusOK, large},
+		{"/large", "br", http.StatusOK, large},
+		{"/large", "gzip;q=0, *;q=0", http.StatusOK, large},
+		{"/small", "gzip", http.StatusOK, "small"},
+		{"/png", "gzip", http.StatusOK, large},
+		{"/empty", "gzip", http.StatusNoContent, ""},
+	}
+	for _, cs := range cases {
+		w := get(cs.path, cs.accept)
+		if w.Code != cs.code || w.Header().Get(CONTENT_ENCODING) != "" || w.Body.String() != cs.body {
+			t.Errorf("GET %s (%s) = %d %d bytes, encoding %q", cs.path, cs.accept, w.Code, w.Body.Len(), w.Header().Get(CONTENT_ENCODING))
+		}
+	}
+	if w := get("/small", "gzip"); w.Header().Get(CONTENT_LENGTH) != "5" {
+		t.Errorf("GET /small Content-Length = %q", w.Header().Get(CONTENT_LENGTH))
+	}
+
+	r := httptest.NewRequest(HEAD, "/large", nil)
+	r.Header.Set(ACCEPT_ENCODING, "gzip")
+	app.ADD(HEAD, "/large", func(c *Context) {
+		c.Text(large)
+	})
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, r)
+	if w.Header().Get(CONTENT_ENCODING) != "" {
+		t.Errorf("HEAD shouldn't be compressed")
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	type Params struct {
+		Name string `json:"name"`
+	}
+	app := New()
+	app.Use(DecompressWithConfig(DecompressConfig{Limit: 64}))
+	app.POST("/users", func(c *Context) {
+		p := Params{}
+		if err := c.Bind(&p); err != nil {
+			c.Error(err)
+			return
+		}
+		c.Text(p.Name)
+	})
+
+	post := func(encoding string, body []byte) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(POST, "/users", bytes.NewReader(body))
+		r.Header.Set(CONTENT_TYPE, MIME_JSON)
+		r.Header.Set(CONTENT_ENCODING, encoding)
+		app.ServeHTTP(w, r)
+		return w
+	}
+	compress := func(encoding, data string) []byte {
+		var buf bytes.Buffer
+		var w io.WriteCloser
+		switch encoding {
+		case ENCODING_GZIP:
+			w = gzip.NewWriter(&buf)
+		case ENCODING_DEFLATE:
+			w = zlib.NewWriter(&buf)
+		default:
+			w, _ = flate.NewWriter(&buf, flate.BestSpeed)
+		}
+		w.Write([]byte(data))
+		w.Close()
+		return buf.Bytes()
+	}
+
+	json := `{"name":"goil"}`
+	cases := []struct {
+		encoding string
+		body     []byte
+		code     int
+		resp     string
+	}{
+		{"", []byte(json), http.StatusOK, "goil"},
+		{"gzip", compress(ENCODING_GZIP, json), http.StatusOK, "goil"},
+		{"deflate", compress(ENCODING_DEFLATE, json), http.StatusOK, "goil"},
+		{"deflate", compress("raw", json), http.StatusOK, "goil"},
+		{"gzip", []byte(json), http.StatusBadRequest, ""},
+		{"br", []byte(json), http.StatusUnsupportedMediaType, ""},
+		//the zip bomb
+		{"gzip", compress(ENCODING_GZIP, `{"name":"`+strings.Repeat("a", 1<<20)+`"}`), http.StatusRequestEntityTooLarge, ""},
+	}
+	for _, cs := range cases {
+		w := post(cs.encoding, cs.body)
+		if w.Code != cs.code || (cs.resp != "" && w.Body.String() != cs.resp) {
+			t.Errorf("POST %s = %d %q; want %d %q", cs.encoding, w.Code, w.Body.String(), cs.code, cs.resp)
+		}
+	}
+}
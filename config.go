@@ -2,6 +2,9 @@ package goil
 
 import (
 	"fmt"
+	"goil/logger"
+	"net"
+	"net/http"
 	"os"
 	"strings"
 	"sync"
@@ -24,6 +27,9 @@ const (
 //the env is dbg or uat or prd
 var run_mode = DBG_MODE
 
+//protect the package level defaults
+var defaultsMu sync.RWMutex
+
 func init() {
 	env := os.Getenv(ENV_KEY)
 	switch strings.ToUpper(env) {
@@ -36,14 +42,23 @@ func init() {
 	}
 }
 
+//SetMode sets the default run mode of the apps, it can be overridden by WithMode
 func SetMode(mode int) {
-	guard.execSafely(func() {
-		run_mode = mode
-	})
+	defaultsMu.Lock()
+	run_mode = mode
+	defaultsMu.Unlock()
 }
 
+//RunMode returns the default run mode of the apps
 func RunMode() string {
-	switch run_mode {
+	defaultsMu.RLock()
+	mode := run_mode
+	defaultsMu.RUnlock()
+	return modeName(mode)
+}
+
+func modeName(mode int) string {
+	switch mode {
 	case PRD_MODE:
 		return PRD
 	case UAT_MODE:
@@ -51,23 +66,253 @@ func RunMode() string {
 	case DBG_MODE:
 		return DBG
 	}
-	panic(fmt.Sprintf("unsupport run mode: %d", run_mode))
+	panic(fmt.Sprintf("unsupport run mode: %d", mode))
+}
+
+//Config is the configuration of an App, it is set by the options of New:
+//	app := goil.New(goil.WithMode(goil.PRD_MODE), goil.WithLogger(l))
+//the binders, converts and validators of the app are looked up firstly,
+//then the package level ones registered by RegisterParamsHandler, RegisterConvert and RegisterValidator
+type Config struct {
+	//DBG_MODE, UAT_MODE or PRD_MODE, the default is set by the env GOIL_MODE or SetMode
+	Mode int
+	//the logger of the app, the default is the logger of package logger
+	Logger logger.ILogger
+	//the binders of the request body by the mime type
+	Binders map[string]ParamsBinder
+	//the converts used by the `convert` tag and the constraints of the path params
+	Converts map[string]Convert
+	//the validators used by the `validator` tag
+	Validators map[string]ＶalidateFunc
+	//the render of Context.Html, the default is HtmlRender
+	HtmlRender *HtmlTemp
+	//the max bytes of the request body, reading over it responds 413, zero means no limit.
+	//it can be changed for the route by BodyLimit
+	MaxBodySize int64
+	//the max bytes of the multipart form stored in memory, DEFAULT_SIZE is used if it is zero
+	MultipartMemory int64
+	//the max bytes of the uploaded files stored in the temp files, zero means no limit
+	MultipartDiskLimit int64
+	//the proxies whose forwarding headers are trusted, see App.SetTrustedProxies
+	TrustedProxies []*net.IPNet
+	//the header of the client set by the trusted proxies, such as FORWARDED or X_REAL_IP,
+	//X_FORWARDED_FOR is used if it is empty. the other forwarding headers are ignored,
+	//since the clients can send them through the proxies
+	ForwardedHeader string
+	//the keys of the signed and encrypted cookies, see WithCookieKeys
+	CookieKeys [][]byte
+}
+
+//Option configures the App created by New
+type Option func(*Config)
+
+//WithMode sets the run mode of the app
+func WithMode(mode int) Option {
+	return func(conf *Config) {
+		modeName(mode)
+		conf.Mode = mode
+	}
+}
+
+//WithLogger sets the logger of the app
+func WithLogger(l logger.ILogger) Option {
+	return func(conf *Config) {
+		conf.Logger = l
+	}
+}
+
+//WithParamsHandler registers the binder of the request body for the app
+func WithParamsHandler(mime string, handler ParamsBinder) Option {
+	return func(conf *Config) {
+		if conf.Binders == nil {
+			conf.Binders = make(map[string]ParamsBinder)
+		}
+		conf.Binders[mime] = handler
+	}
+}
+
+//WithConvert registers the convert for the app
+func WithConvert(name string, fun Convert) Option {
+	return func(conf *Config) {
+		if conf.Converts == nil {
+			conf.Converts = make(map[string]Convert)
+		}
+		conf.Converts[name] = fun
+	}
+}
+
+//WithValidator registers the validator for the app
+func WithValidator(name string, fun ＶalidateFunc) Option {
+	return func(conf *Config) {
+		if conf.Validators == nil {
+			conf.Validators = make(map[string]ＶalidateFunc)
+		}
+		conf.Validators[name] = fun
+	}
+}
+
+//WithHtmlRender sets the html templates of the app, see NewHtmlTemp
+func WithHtmlRender(render *HtmlTemp) Option {
+	return func(conf *Config) {
+		conf.HtmlRender = render
+	}
+}
+
+//WithBodyLimit sets the max bytes of the request body
+func WithBodyLimit(size int64) Option {
+	return func(conf *Config) {
+		conf.MaxBodySize = size
+	}
+}
+
+//WithMultipart sets the max bytes of the multipart form stored in memory and in the temp files
+func WithMultipart(memory, diskLimit int64) Option {
+	return func(conf *Config) {
+		conf.MultipartMemory = memory
+		conf.MultipartDiskLimit = diskLimit
+	}
+}
+
+//WithCookieKeys sets the keys of the signed and encrypted cookies, the first key signs and encrypts,
+//and all keys verify and decrypt, so the keys can be rotated by prepending the new one
+func WithCookieKeys(keys ...[]byte) Option {
+	return func(conf *Config) {
+		conf.CookieKeys = keys
+	}
+}
+
+//WithForwardedHeader sets the header of the client set by the trusted proxies
+func WithForwardedHeader(header string) Option {
+	return func(conf *Config) {
+		conf.ForwardedHeader = header
+	}
+}
+
+func newConfig(opts ...Option) *Config {
+	defaultsMu.RLock()
+	conf := &Config{
+		Mode: run_mode,
+	}
+	defaultsMu.RUnlock()
+	for _, opt := range opts {
+		opt(conf)
+	}
+	return conf
+}
+
+//the nil config uses the package level defaults
+
+func (conf *Config) mode() string {
+	if conf == nil {
+		return RunMode()
+	}
+	return modeName(conf.Mode)
+}
+
+func (conf *Config) logger() logger.ILogger {
+	if conf == nil || conf.Logger == nil {
+		return logger.Default()
+	}
+	return conf.Logger
+}
+
+func (conf *Config) htmlRender() *HtmlTemp {
+	if conf == nil || conf.HtmlRender == nil {
+		return HtmlRender
+	}
+	return conf.HtmlRender
+}
+
+func (conf *Config) multipartMemory() int64 {
+	if conf == nil || conf.MultipartMemory <= 0 {
+		return DEFAULT_SIZE
+	}
+	return conf.MultipartMemory
+}
+
+func (conf *Config) trustedProxies() []*net.IPNet {
+	if conf == nil {
+		return nil
+	}
+	return conf.TrustedProxies
+}
+
+func (conf *Config) forwardedHeader() string {
+	if conf == nil || conf.ForwardedHeader == "" {
+		return X_FORWARDED_FOR
+	}
+	return http.CanonicalHeaderKey(conf.ForwardedHeader)
 }
 
-//Guard use to ensure register before running
+func (conf *Config) cookieKeys() [][]byte {
+	if conf == nil {
+		return nil
+	}
+	return conf.CookieKeys
+}
+
+func (conf *Config) binder(mime string) (ParamsBinder, bool) {
+	if conf != nil {
+		if binder, exists := conf.Binders[mime]; exists {
+			return binder, true
+		}
+	}
+	defaultsMu.RLock()
+	binder, exists := paramsHandlers[mime]
+	defaultsMu.RUnlock()
+	if exists {
+		return binder, true
+	}
+	//the form binder binds the values by the converts of the app
+	if mime == MIME_POST || mime == MIME_MULT_POST {
+		return conf.bindFormParams, true
+	}
+	return nil, false
+}
+
+func (conf *Config) convert(name string) (Convert, bool) {
+	if conf != nil {
+		if conv, exists := conf.Converts[name]; exists {
+			return conv, true
+		}
+	}
+	defaultsMu.RLock()
+	conv, exists := convertFunc[name]
+	defaultsMu.RUnlock()
+	return conv, exists
+}
+
+func (conf *Config) validator(name string) (ＶalidateFunc, bool) {
+	if conf != nil {
+		if fun, exists := conf.Validators[name]; exists {
+			return fun, true
+		}
+	}
+	defaultsMu.RLock()
+	fun, exists := validateFunc[name]
+	defaultsMu.RUnlock()
+	return fun, exists
+}
+
+//Guard use to ensure register before running, each app has its own guard
 type Guard struct {
 	mu    sync.RWMutex
 	state bool
 }
 
-var guard = new(Guard)
-
 func (g *Guard) run() {
 	g.mu.Lock()
 	g.state = true
 	g.mu.Unlock()
 }
 
+//reset allows registering again, it is called after the server is closed
+func (g *Guard) reset() {
+	g.mu.Lock()
+	g.state = false
+	g.mu.Unlock()
+}
+
 func (g *Guard) execSafely(f func()) {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
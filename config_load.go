@@ -0,0 +1,430 @@
+package goil
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	yaml "gopkg.in/yaml.v2"
+)
+
+//the tag names the key of the field in the config files and the env
+const CONFIG_TAG = "config"
+
+//ConfigDecoder decodes the content of the config file
+type ConfigDecoder func(data []byte) (map[string]interface{}, error)
+
+//the decoders by the extension of the config files
+var configDecoders = map[string]ConfigDecoder{
+	".json": func(data []byte) (values map[string]interface{}, err error) {
+		err = json.Unmarshal(data, &values)
+		return
+	},
+	".yaml": decodeYAML,
+	".yml":  decodeYAML,
+	".toml": func(data []byte) (values map[string]interface{}, err error) {
+		err = toml.Unmarshal(data, &values)
+		return
+	},
+}
+
+func decodeYAML(data []byte) (values map[string]interface{}, err error) {
+	err = yaml.Unmarshal(data, &values)
+	return
+}
+
+//RegisterConfigDecoder registers the decoder for the config files with the extension, such as ".ini"
+func RegisterConfigDecoder(ext string, decoder ConfigDecoder) {
+	defaultsMu.Lock()
+	configDecoders[strings.ToLower(ext)] = decoder
+	defaultsMu.Unlock()
+}
+
+//ConfigLoader loads the config files and the env into the user struct:
+//	type Config struct {
+//		Server goil.ServerOptions `config:"server"`
+//		DB     struct {
+//			DSN string `config:"dsn" validator:"required"`
+//		} `config:"db"`
+//	}
+//	conf := Config{}
+//	loader := goil.ConfigLoader{Files: []string{"app.yaml"}, EnvPrefix: "APP"}
+//	if err := loader.Load(&conf); err != nil {
+//		panic(err)
+//	}
+//	app.Server(conf.Server)
+//the keys of the files match the fields by the config tag or the field name, ignoring the case and '_',
+//the profile of the run mode such as app.prd.yaml overrides the file, then the env such as APP_DB_DSN
+//overrides the files, and the struct is validated by the validator tags at last
+type ConfigLoader struct {
+	//the config files loaded in order, the format is detected by the extension
+	Files []string
+	//the prefix of the env overriding the config, the env isn't loaded if it is empty
+	EnvPrefix string
+	//the run mode selecting the profiles, RunMode is used if it is empty
+	Mode string
+}
+
+//LoadConfig loads the files and the profiles of the run mode into the struct
+func LoadConfig(iface interface{}, files ...string) error {
+	loader := ConfigLoader{
+		Files: files,
+	}
+	return loader.Load(iface)
+}
+
+//Load loads the config into the struct pointed by iface
+func (l *ConfigLoader) Load(iface interface{}) error {
+	if iface == nil || !isPtr(iface) {
+		return fmt.Errorf("the config must be a pointer to struct")
+	}
+	mode := l.Mode
+	if mode == "" {
+		mode = RunMode()
+	}
+	values := make(map[string]interface{})
+	for _, file := range l.Files {
+		m, err := readConfigFile(file)
+		if err != nil {
+			return err
+		}
+		mergeConfig(values, m)
+
+		profile := profileFile(file, mode)
+		if _, err := os.Stat(profile); err != nil {
+			continue
+		}
+		m, err = readConfigFile(profile)
+		if err != nil {
+			return err
+		}
+		mergeConfig(values, m)
+	}
+
+	dv := valueOf(iface).Elem()
+	if err := decodeConfig(values, dv, ""); err != nil {
+		return err
+	}
+	if l.EnvPrefix != "" {
+		if err := loadEnv(dv, strings.ToUpper(l.EnvPrefix)); err != nil {
+			return err
+		}
+	}
+	return validate(nil, iface)
+}
+
+//profileFile returns the profile of the file for the mode, such as app.prd.yaml for app.yaml
+func profileFile(file, mode string) string {
+	ext := filepath.Ext(file)
+	return strings.TrimSuffix(file, ext) + "." + strings.ToLower(mode) + ext
+}
+
+func readConfigFile(file string) (map[string]interface{}, error) {
+	ext := strings.ToLower(filepath.Ext(file))
+	defaultsMu.RLock()
+	decoder, exists := configDecoders[ext]
+	defaultsMu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("unsupported config file: %s", file)
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	values, err := decoder(data)
+	if err != nil {
+		return nil, fmt.Errorf("when parsing %s: %s", file, err)
+	}
+	return values, nil
+}
+
+//configKey normalizes the key, so read_timeout, readTimeout and ReadTimeout are the same
+func configKey(key string) string {
+	key = strings.ToLower(key)
+	key = strings.Replace(key, "_", "", -1)
+	return strings.Replace(key, "-", "", -1)
+}
+
+//normalize converts the maps decoded by yaml to map[string]interface{} with the normalized keys
+func normalize(src interface{}) interface{} {
+	switch val := src.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, v := range val {
+			m[configKey(k)] = normalize(v)
+		}
+		return m
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, v := range val {
+			m[configKey(fmt.Sprint(k))] = normalize(v)
+		}
+		return m
+	case []interface{}:
+		for i, v := range val {
+			val[i] = normalize(v)
+		}
+	case []map[string]interface{}:
+		//the array of tables in toml
+		s := make([]interface{}, len(val))
+		for i, v := range val {
+			s[i] = normalize(v)
+		}
+		return s
+	}
+	return src
+}
+
+//mergeConfig merges src into dst, the nested maps are merged recursively
+func mergeConfig(dst, src map[string]interface{}) {
+	for k, v := range normalize(src).(map[string]interface{}) {
+		sm, ok1 := v.(map[string]interface{})
+		dm, ok2 := dst[k].(map[string]interface{})
+		if ok1 && ok2 {
+			mergeConfig(dm, sm)
+			continue
+		}
+		dst[k] = v
+	}
+}
+
+//fieldKey returns the key of the field in the config, the field is skipped if the key is "-"
+func fieldKey(field reflect.StructField) string {
+	key := field.Tag.Get(CONFIG_TAG)
+	if idx := strings.IndexByte(key, ','); idx >= 0 {
+		key = key[:idx]
+	}
+	if key == "" {
+		key = field.Name
+	}
+	return key
+}
+
+var durationTyp = reflect.TypeOf(time.Duration(0))
+var textUnmarshalerTyp = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+//decodeConfig decodes the value of the config into dv, the path is used by the error message
+func decodeConfig(src interface{}, dv reflect.Value, path string) error {
+	if src == nil {
+		return nil
+	}
+	sv := valueOf(src)
+	if sv.Type().AssignableTo(dv.Type()) {
+		dv.Set(sv)
+		return nil
+	}
+	if dv.Kind() == reflect.Ptr {
+		dv, _ = dereference(dv, dv.Type())
+		return decodeConfig(src, dv, path)
+	}
+	if str, ok := src.(string); ok && reflect.PtrTo(dv.Type()).Implements(textUnmarshalerTyp) {
+		if err := dv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(str)); err != nil {
+			return fmt.Errorf("config %s: %s", path, err)
+		}
+		return nil
+	}
+
+	switch dv.Kind() {
+	case reflect.Struct:
+		m, ok := src.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("config %s: %T can't be decoded into %s", path, src, dv.Type())
+		}
+		typ := dv.Type()
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if !export(field.Name) {
+				continue
+			}
+			key := fieldKey(field)
+			if key == "-" {
+				continue
+			}
+			//the embedded struct shares the keys of the parent
+			if field.Anonymous && field.Tag.Get(CONFIG_TAG) == "" && IsStructReally(field.Type) {
+				if err := decodeConfig(m, dv.Field(i), path); err != nil {
+					return err
+				}
+				continue
+			}
+			if v, exists := m[configKey(key)]; exists {
+				if err := decodeConfig(v, dv.Field(i), joinKey(path, key)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case reflect.Map:
+		m, ok := src.(map[string]interface{})
+		if !ok || dv.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("config %s: %T can't be decoded into %s", path, src, dv.Type())
+		}
+		if dv.IsNil() {
+			dv.Set(reflect.MakeMap(dv.Type()))
+		}
+		for k, v := range m {
+			ev := reflect.New(dv.Type().Elem()).Elem()
+			if err := decodeConfig(v, ev, joinKey(path, k)); err != nil {
+				return err
+			}
+			dv.SetMapIndex(reflect.ValueOf(k).Convert(dv.Type().Key()), ev)
+		}
+		return nil
+	case reflect.Slice:
+		s, ok := src.([]interface{})
+		if !ok {
+			//the comma separated values
+			str, isStr := src.(string)
+			if !isStr {
+				return fmt.Errorf("config %s: %T can't be decoded into %s", path, src, dv.Type())
+			}
+			s = make([]interface{}, 0)
+			for _, item := range strings.Split(str, ",") {
+				s = append(s, strings.TrimSpace(item))
+			}
+		}
+		sl := reflect.MakeSlice(dv.Type(), len(s), len(s))
+		for i, v := range s {
+			if err := decodeConfig(v, sl.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		dv.Set(sl)
+		return nil
+	}
+
+	var str string
+	switch val := src.(type) {
+	case string:
+		str = val
+	case float64:
+		str = strconv.FormatFloat(val, 'f', -1, 64)
+	case int, int64, uint64, bool:
+		str = fmt.Sprint(val)
+	default:
+		return fmt.Errorf("config %s: %T can't be decoded into %s", path, src, dv.Type())
+	}
+	if err := setConfigValue(dv, str); err != nil {
+		return fmt.Errorf("config %s: %s", path, err)
+	}
+	return nil
+}
+
+//setConfigValue sets the scalar value from the string
+func setConfigValue(dv reflect.Value, str string) error {
+	if dv.Type() == durationTyp {
+		d, err := time.ParseDuration(str)
+		if err != nil {
+			//the seconds
+			secs, err2 := strconv.ParseInt(str, 10, 64)
+			if err2 != nil {
+				return err
+			}
+			d = time.Duration(secs) * time.Second
+		}
+		dv.SetInt(int64(d))
+		return nil
+	}
+	switch dv.Kind() {
+	case reflect.String:
+		dv.SetString(str)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(str, 10, dv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(str, 10, dv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dv.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(str, dv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dv.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(str)
+		if err != nil {
+			return err
+		}
+		dv.SetBool(b)
+	case reflect.Interface:
+		dv.Set(valueOf(str))
+	default:
+		return fmt.Errorf("unsupported type %s", dv.Type())
+	}
+	return nil
+}
+
+func joinKey(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+//loadEnv overrides the fields by the env, the name of the env is the prefix and the keys
+//of the fields joined by '_' in upper case, such as APP_SERVER_READ_TIMEOUT
+func loadEnv(dv reflect.Value, prefix string) error {
+	dv, typ := dereference(dv, dv.Type())
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !export(field.Name) {
+			continue
+		}
+		key := fieldKey(field)
+		if key == "-" {
+			continue
+		}
+		fv := dv.Field(i)
+		name := prefix
+		if !field.Anonymous || field.Tag.Get(CONFIG_TAG) != "" {
+			name += "_" + envKey(key)
+		}
+		value, exists := os.LookupEnv(name)
+		if exists {
+			if err := decodeConfig(value, fv, name); err != nil {
+				return err
+			}
+			continue
+		}
+		ft := deref(field.Type)
+		if ft.Kind() == reflect.Struct && !reflect.PtrTo(ft).Implements(textUnmarshalerTyp) {
+			if err := loadEnv(fv, name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//envKey converts the key to upper snake case, such as ReadTimeout to READ_TIMEOUT
+func envKey(key string) string {
+	buf := make([]byte, 0, len(key)+4)
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if c == '-' || c == '.' {
+			c = '_'
+		}
+		if c >= 'A' && c <= 'Z' && i > 0 {
+			prev := key[i-1]
+			if prev >= 'a' && prev <= 'z' || prev >= '0' && prev <= '9' {
+				buf = append(buf, '_')
+			}
+		}
+		buf = append(buf, c)
+	}
+	return strings.ToUpper(string(buf))
+}
@@ -0,0 +1,112 @@
+package goil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type dbConfig struct {
+	DSN      string `config:"dsn" validator:"required"`
+	MaxConns int
+}
+
+type appConfig struct {
+	Server ServerOptions `config:"server"`
+	DB     dbConfig      `config:"db"`
+	Tags   []string
+	Labels map[string]string
+}
+
+func writeConfig(t *testing.T, dir, name, content string) string {
+	file := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestConfigLoader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goil-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	yml := writeConfig(t, dir, "app.yaml", `
+server:
+  addr: ":8080"
+  read_timeout: 5s
+  shutdown_timeout: 30
+db:
+  dsn: "root@/dev"
+  max_conns: 10
+tags: [a, b]
+labels:
+  team: web
+`)
+	writeConfig(t, dir, "app.prd.yaml", `
+server:
+  addr: ":80"
+db:
+  dsn: "root@/prd"
+`)
+	tml := writeConfig(t, dir, "extra.toml", `
+[server]
+cert_file = "cert.pem"
+key_file = "key.pem"
+`)
+	js := writeConfig(t, dir, "extra.json", `{"server": {"IdleTimeout": "1m"}, "db": {"maxConns": 20}}`)
+
+	os.Setenv("GOILTEST_SERVER_WRITE_TIMEOUT", "10s")
+	os.Setenv("GOILTEST_DB_MAX_CONNS", "30")
+	os.Setenv("GOILTEST_TAGS", "x, y")
+	defer os.Unsetenv("GOILTEST_SERVER_WRITE_TIMEOUT")
+	defer os.Unsetenv("GOILTEST_DB_MAX_CONNS")
+	defer os.Unsetenv("GOILTEST_TAGS")
+
+	conf := appConfig{}
+	loader := ConfigLoader{
+		Files:     []string{yml, tml, js},
+		EnvPrefix: "goiltest",
+		Mode:      PRD,
+	}
+	if err := loader.Load(&conf); err != nil {
+		t.Fatalf("Load: %s", err)
+	}
+	want := ServerOptions{
+		Addr:            ":80",
+		CertFile:        "cert.pem",
+		KeyFile:         "key.pem",
+		ReadTimeout:     5 * time.Second,
+		WriteTimeout:    10 * time.Second,
+		IdleTimeout:     time.Minute,
+		ShutdownTimeout: 30 * time.Second,
+	}
+	if conf.Server.Addr != want.Addr || conf.Server.CertFile != want.CertFile || conf.Server.KeyFile != want.KeyFile ||
+		conf.Server.ReadTimeout != want.ReadTimeout || conf.Server.WriteTimeout != want.WriteTimeout ||
+		conf.Server.IdleTimeout != want.IdleTimeout || conf.Server.ShutdownTimeout != want.ShutdownTimeout {
+		t.Errorf("Server = %+v; want %+v", conf.Server, want)
+	}
+	if conf.DB.DSN != "root@/prd" || conf.DB.MaxConns != 30 {
+		t.Errorf("DB = %+v", conf.DB)
+	}
+	if len(conf.Tags) != 2 || conf.Tags[0] != "x" || conf.Tags[1] != "y" {
+		t.Errorf("Tags = %v", conf.Tags)
+	}
+	if conf.Labels["team"] != "web" {
+		t.Errorf("Labels = %v", conf.Labels)
+	}
+
+	//the dev profile doesn't exist, and the validator fails without dsn
+	empty := writeConfig(t, dir, "empty.yaml", "server:\n  addr: \":8080\"\n")
+	conf = appConfig{}
+	if err := LoadConfig(&conf, empty); err == nil {
+		t.Errorf("LoadConfig should fail when the dsn is missing")
+	}
+	if err := LoadConfig(&conf, filepath.Join(dir, "app.ini")); err == nil {
+		t.Errorf("LoadConfig should fail for the unsupported format")
+	}
+}
@@ -15,6 +15,8 @@ import (
 	"time"
 )
 
+//Context implements context.Context by the request context. it is reused by the following requests
+//once the handlers return, so pass Copy() to the goroutines which outlive the handlers
 type Context struct {
 	Request  *http.Request
 	resp     response
@@ -22,10 +24,20 @@ type Context struct {
 	chain    HandlerChain
 	idx      int
 	params   Params
+	route    *Route
+	//the app serving the request
+	app *App
 	//ErrMsg and ErrCode is used pass err info among middlewares
 	ErrMsg  error
 	ErrCode int
 	values  *concurrentMap
+	//the errors recorded by Error
+	errors []error
+	//whether the last error has been handled by the App.ErrorHandler
+	errHandled bool
+	//the cancel funcs of the contexts derived by WithTimeout and WithDeadline,
+	//they will be called when the context is released
+	cancels []context.CancelFunc
 }
 
 //执行 middleware chain 的下一个节点
@@ -43,6 +55,9 @@ func (ctx *Context) Next() {
 		ctx.idx++
 		handler(ctx)
 	}
+	//the handlers are done, handle the error before the middlewares go on after Next,
+	//so they see the status of the error response, such as PrintRequestInfo
+	ctx.handleError()
 }
 
 //获取 middleware chain 的下一个节点 handler
@@ -91,22 +106,23 @@ func (c *Context) GetCookie(name string) (string, error) {
 	return value, nil
 }
 
-//set cookie to response
+//set cookie to response, see SetCookieWithOptions for more attributes
 func (c *Context) SetCookie(name, value string, maxAge int, path, domain string, secure, httpOnly bool) {
-	if path == "" {
-		path = "/"
-	}
-	http.SetCookie(c.Response, &http.Cookie{
-		Name:     name,
-		Value:    url.QueryEscape(value),
-		MaxAge:   maxAge,
+	c.SetCookieWithOptions(name, value, CookieOptions{
 		Path:     path,
 		Domain:   domain,
+		MaxAge:   maxAge,
 		Secure:   secure,
 		HttpOnly: httpOnly,
 	})
 }
 
+//BeforeWrite registers the func called before the header of the response is written,
+//such as setting the cookies lazily, the funcs are called in the reverse order of registering
+func (c *Context) BeforeWrite(fn func()) {
+	c.resp.before = append(c.resp.before, fn)
+}
+
 func (c *Context) Flush() {
 	c.Response.Flush()
 }
@@ -124,6 +140,19 @@ func (c *Context) Param(key string) (value string) {
 	return
 }
 
+//Route returns the matched route, which carries the path pattern and the metadata,
+//it returns nil if no route is matched
+func (c *Context) Route() *Route {
+	return c.route
+}
+
+//TypedParam returns the value converted by the constraint of the path param,
+//such as int64 for :id<int>, it returns the raw string if the param has no constraint
+func (c *Context) TypedParam(key string) (value interface{}) {
+	value, _ = c.params.getTyped(key)
+	return
+}
+
 func (c *Context) DefParam(key string, def string) string {
 	if value, exist := c.params.get(key); exist {
 		return value
@@ -147,13 +176,14 @@ func (c *Context) DefQuery(key string, def string) string {
 }
 
 func (c *Context) BindQuery(iface interface{}) error {
-	err := bindQueryParams(c.Request, iface)
+	conf := c.config()
+	err := bindQueryParams(conf, c.Request, iface)
 	if err != nil {
-		logger.Errorf("when binding params: %s", err)
+		conf.logger().Errorf("when binding params: %s", err)
 		return ParamsBindingError
 	}
 
-	err = validate(iface)
+	err = validate(conf, iface)
 
 	if err != nil {
 		verr := ValidatorError{}
@@ -198,7 +228,15 @@ func (c *Context) DefFormValue(key, def string) string {
 	return def
 }
 
+//parseMultipart parses the multipart form within the limits of the app
+func (c *Context) parseMultipart() error {
+	return c.config().parseMultipart(c.Request)
+}
+
 func (c *Context) SaveFile(name, dest string) error {
+	if err := c.parseMultipart(); err != nil {
+		return err
+	}
 	_, fh, err := c.Request.FormFile(name)
 	if err != nil {
 		return err
@@ -221,11 +259,15 @@ func (c *Context) SaveFile(name, dest string) error {
 func (c *Context) Bind(iface interface{}) error {
 	err := bind(c, iface)
 	if err != nil {
-		logger.Errorf("when binding params: %s", err)
+		c.Logger().Errorf("when binding params: %s", err)
+		//such as RequestTooLarge
+		if he, ok := err.(*HTTPError); ok {
+			return he
+		}
 		return ParamsBindingError
 	}
 
-	err = validate(iface)
+	err = validate(c.config(), iface)
 
 	if err != nil {
 		verr := ValidatorError{}
@@ -247,7 +289,13 @@ func (c *Context) ContentType(contentType string) {
 }
 
 func (c *Context) Html(name string, data interface{}) {
-	c.Render(HtmlRender, VM(name, data))
+	vm := VM(name, data)
+	if c.app != nil {
+		vm.url = c.app.URL
+	}
+	vm.nonce = c.CSPNonce()
+	vm.csrfName, vm.csrfToken = c.csrfForm()
+	c.Render(c.config().htmlRender(), vm)
 }
 
 //write the raw text
@@ -277,7 +325,7 @@ func (c *Context) Render(r Render, content interface{}) {
 	c.ContentType(contentType)
 	err := r.Render(c.Response, content)
 	if err != nil {
-		panic(err)
+		c.Error(err)
 	}
 }
 
@@ -288,7 +336,8 @@ func (c *Context) File(filepath string) {
 func (c *Context) IndentJSON(content interface{}) {
 	byts, err := json.MarshalIndent(content, "", " ")
 	if err != nil {
-		panic(err)
+		c.Error(err)
+		return
 	}
 	c.Body(MIME_JSON, byts)
 }
@@ -310,34 +359,41 @@ func (c *Context) Stream(contentType string, r io.Reader, autoClose bool) {
 	c.Response.SetHeader(CONTENT_TYPE, contentType)
 	_, err := io.Copy(c.Response, r)
 	if err != nil {
-		panic(err)
+		c.Error(err)
 	}
 }
 
+//ClientIP returns the IP of the client, the forwarding headers are only respected
+//if the request is from the trusted proxies, see App.SetTrustedProxies
 func (c *Context) ClientIP() string {
-
-	clientIP := c.Headers().Get("X-Forwarded-For")
-	if index := strings.IndexByte(clientIP, ','); index >= 0 {
-		clientIP = clientIP[0:index]
-	}
-	clientIP = strings.TrimSpace(clientIP)
-	if clientIP != "" {
-		return clientIP
+	if hop, ok := c.forwarded(); ok && hop.ip != "" {
+		return hop.ip
 	}
-	clientIP = strings.TrimSpace(c.Headers().Get("X-Real-Ip"))
-	if clientIP != "" {
-		return clientIP
+	if ip, _, err := net.SplitHostPort(strings.TrimSpace(c.Request.RemoteAddr)); err == nil {
+		return ip
 	}
+	return ""
+}
 
-	if addr := c.Headers().Get("X-Appengine-Remote-Addr"); addr != "" {
-		return addr
+//Scheme returns "https" or "http" which the client requests with,
+//the proto forwarded by the trusted proxies is respected
+func (c *Context) Scheme() string {
+	if hop, ok := c.forwarded(); ok && hop.proto != "" {
+		return strings.ToLower(hop.proto)
 	}
-
-	if ip, _, err := net.SplitHostPort(strings.TrimSpace(c.Request.RemoteAddr)); err == nil {
-		return ip
+	if c.Request.TLS != nil {
+		return "https"
 	}
+	return "http"
+}
 
-	return ""
+//Host returns the host which the client requests,
+//the host forwarded by the trusted proxies is respected
+func (c *Context) Host() string {
+	if hop, ok := c.forwarded(); ok && hop.host != "" {
+		return hop.host
+	}
+	return c.Request.Host
 }
 
 func (c *Context) Get(key string) (val interface{}, exists bool) {
@@ -374,14 +430,44 @@ func (c *Context) Del(key string) {
 }
 
 func (c *Context) clear() {
+	//remove the temp files of the uploaded files
+	if c.Request != nil && c.Request.MultipartForm != nil {
+		c.Request.MultipartForm.RemoveAll()
+	}
 	c.Request = nil
 	c.resp.clear()
 	c.Response = nil
 	c.chain = nil
 	c.values = nil
 	c.params = nil
+	c.route = nil
 	c.ErrMsg = nil
 	c.ErrCode = 0
+	for i := range c.errors {
+		c.errors[i] = nil
+	}
+	c.errors = c.errors[:0]
+	c.errHandled = false
+	//release the timers and stop the derived contexts,
+	//so the cancellation won't leak to the next request
+	for i, cancel := range c.cancels {
+		cancel()
+		c.cancels[i] = nil
+	}
+	c.cancels = c.cancels[:0]
+}
+
+//config returns the config of the app, nil for the context which isn't created by an app
+func (c *Context) config() *Config {
+	if c.app == nil {
+		return nil
+	}
+	return c.app.conf
+}
+
+//Logger returns the logger of the app
+func (c *Context) Logger() logger.ILogger {
+	return c.config().logger()
 }
 
 func (c *Context) reset(w http.ResponseWriter, r *http.Request) {
@@ -398,19 +484,100 @@ func (c *Context) Redirect(code int, location string) {
 	c.Response.WriteHeader(code)
 }
 
+//Deadline returns the deadline of the request context
 func (c *Context) Deadline() (deadline time.Time, ok bool) {
-	return
+	if c.Request == nil {
+		return
+	}
+	return c.Request.Context().Deadline()
 }
 
+//Done returns a channel that's closed when the client disconnects,
+//the request times out or the handlers are done
 func (c *Context) Done() <-chan struct{} {
-	return nil
+	if c.Request == nil {
+		//the context has been released
+		return closedChan
+	}
+	return c.Request.Context().Done()
 }
 
+//Err returns the reason why the Done channel is closed,
+//use ErrMsg to pass the err info among middlewares
 func (c *Context) Err() error {
-	return c.ErrMsg
+	if c.Request == nil {
+		return context.Canceled
+	}
+	return c.Request.Context().Err()
 }
 
+//Value returns the value set by Set firstly, and then the value of the request context
 func (c *Context) Value(key interface{}) interface{} {
-	val, _ := c.values.get(key)
-	return val
+	if c.values != nil {
+		if val, exists := c.values.get(key); exists {
+			return val
+		}
+	}
+	if c.Request == nil {
+		return nil
+	}
+	return c.Request.Context().Value(key)
+}
+
+//WithTimeout replaces the request context with a derived one which is canceled after the timeout,
+//the cancel func is called automatically when the request is done
+func (c *Context) WithTimeout(timeout time.Duration) context.CancelFunc {
+	return c.WithDeadline(time.Now().Add(timeout))
+}
+
+//WithDeadline replaces the request context with a derived one which is canceled at the deadline,
+//the cancel func is called automatically when the request is done
+func (c *Context) WithDeadline(deadline time.Time) context.CancelFunc {
+	ctx, cancel := context.WithDeadline(c.Request.Context(), deadline)
+	c.Request = c.Request.WithContext(ctx)
+	c.cancels = append(c.cancels, cancel)
+	return cancel
+}
+
+//WithValue replaces the request context with a derived one which carries the value
+func (c *Context) WithValue(key, val interface{}) {
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), key, val))
+}
+
+//Copy returns a copy of the context that can be used outside the request scope,
+//such as passing to a goroutine, the copy can't write the response and call the handlers.
+//the copy keeps the request context, which is done when the request is done
+func (c *Context) Copy() *Context {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	c.cancels = append(c.cancels, cancel)
+	cp := &Context{
+		Request: c.Request.WithContext(ctx),
+		Response: &copiedResponse{
+			header: c.Response.Header().Clone(),
+			status: c.Response.Status(),
+			size:   c.Response.Size(),
+		},
+		params:  make(Params, len(c.params)),
+		route:   c.route,
+		app:     c.app,
+		ErrMsg:  c.ErrMsg,
+		ErrCode: c.ErrCode,
+	}
+	copy(cp.params, c.params)
+	cp.errors = append(cp.errors, c.errors...)
+	if c.values != nil {
+		cp.values = cmNil.new()
+		c.values.RLock()
+		for k, v := range c.values.values {
+			cp.values.values[k] = v
+		}
+		c.values.RUnlock()
+	}
+	return cp
+}
+
+var closedChan = make(chan struct{})
+
+func init() {
+	close(closedChan)
 }
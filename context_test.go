@@ -0,0 +1,177 @@
+package goil
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type ctxKey string
+
+func TestContextDelegatesToRequest(t *testing.T) {
+	app := New()
+	var done <-chan struct{}
+	var released *Context
+	app.GET("/ctx", func(c *Context) {
+		if _, ok := c.Deadline(); ok {
+			t.Error("unexpected deadline")
+		}
+		c.WithValue(ctxKey("user"), "Jim")
+		cancel := c.WithTimeout(time.Hour)
+		defer cancel()
+		if _, ok := c.Deadline(); !ok {
+			t.Error("the deadline should be set")
+		}
+		c.Set("trace", "abc")
+		if v := c.Value(ctxKey("user")); v != "Jim" {
+			t.Errorf("Value(user) = %v", v)
+		}
+		if v := c.Value("trace"); v != "abc" {
+			t.Errorf("Value(trace) = %v", v)
+		}
+		if c.Err() != nil {
+			t.Errorf("Err() = %s", c.Err())
+		}
+		done = c.Done()
+		released = c
+	})
+
+	parent, cancel := context.WithCancel(context.Background())
+	r := httptest.NewRequest(GET, "/ctx", nil).WithContext(parent)
+	app.ServeHTTP(httptest.NewRecorder(), r)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("the derived context should be canceled when the request is done")
+	}
+	//the released context doesn't carry the old request
+	if released.Err() != context.Canceled || released.Value(ctxKey("user")) != nil {
+		t.Error("the released context leaks the request")
+	}
+}
+
+func TestContextReleasedOnPanic(t *testing.T) {
+	app := New()
+	var done <-chan struct{}
+	app.GET("/panic", func(c *Context) {
+		c.WithTimeout(time.Hour)
+		done = c.Done()
+		panic("boom")
+	})
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("the panic should reach the server without Recover")
+			}
+		}()
+		serve(app, GET, "/panic")
+	}()
+	select {
+	case <-done:
+	default:
+		t.Error("the derived context should be canceled when the handler panics")
+	}
+}
+
+func TestContextCanceledByClient(t *testing.T) {
+	app := New()
+	app.GET("/wait", func(c *Context) {
+		select {
+		case <-c.Done():
+			c.Status(http.StatusServiceUnavailable)
+		case <-time.After(time.Second):
+			c.Text("timeout")
+		}
+		if c.Err() != context.Canceled {
+			t.Errorf("Err() = %v; want %v", c.Err(), context.Canceled)
+		}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(GET, "/wait", nil).WithContext(ctx))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("code = %d; want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestHtml(t *testing.T) {
+	html := NewHtmlTemp()
+	html.Method("title", strings.ToUpper)
+	template.Must(html.T.New("page").Parse(`<h1>{{title .}}</h1>`))
+	template.Must(html.T.New("broken").Parse(`<h1>{{title .}}</h1>{{index . 5}}`))
+	app := New(WithHtmlRender(html))
+	app.GET("/page", func(c *Context) { c.Html("page", "goil") })
+	app.GET("/broken", func(c *Context) { c.Html("broken", "goil") })
+	app.GET("/late", func(c *Context) { c.Html("late", "goil") })
+	app.GET("/asset", func(c *Context) { c.Html("asset", nil) })
+
+	for i := 0; i < 3; i++ {
+		if body := serve(app, GET, "/page").Body.String(); body != `<h1>GOIL</h1>` {
+			t.Fatalf("GET /page = %q", body)
+		}
+	}
+	//the failed template writes nothing
+	w := serve(app, GET, "/broken")
+	if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "<h1>") {
+		t.Errorf("GET /broken = %d %q", w.Code, w.Body.String())
+	}
+	//the templates and the funcs added after rendering
+	template.Must(html.T.New("late").Parse(`<p>{{title .}}</p>`))
+	if body := serve(app, GET, "/late").Body.String(); body != `<p>GOIL</p>` {
+		t.Errorf("GET /late = %q", body)
+	}
+	html.Method("title", strings.Title)
+	if body := serve(app, GET, "/page").Body.String(); body != `<h1>Goil</h1>` {
+		t.Errorf("GET /page = %q", body)
+	}
+	//the funcs of the user aren't replaced by the ones of the request
+	html.Method("url", func(name string) string { return "/static/" + name })
+	template.Must(html.T.New("asset").Parse(`<link href="{{url "a.css"}}" nonce="{{cspNonce}}">`))
+	if body := serve(app, GET, "/asset").Body.String(); body != `<link href="/static/a.css" nonce="">` {
+		t.Errorf("GET /asset = %q", body)
+	}
+}
+
+func TestContextCopy(t *testing.T) {
+	app := New()
+	copied := make(chan *Context, 1)
+	app.GET("/users/:id", func(c *Context) {
+		c.WithValue(ctxKey("user"), c.Param("id"))
+		if c.Param("id") != "1" {
+			return
+		}
+		c.Set("user", "Jim")
+		c.Status(http.StatusAccepted)
+		copied <- c.Copy()
+	})
+	serve(app, GET, "/users/1")
+	//the pooled context is reused by the next request
+	serve(app, GET, "/users/2")
+
+	cp := <-copied
+	if cp.Param("id") != "1" || cp.GetDef("user", "") != "Jim" || cp.Response.Status() != http.StatusAccepted {
+		t.Error("the copy lost the request info")
+	}
+	if _, err := cp.Response.Write([]byte("x")); err != ResponseCopied {
+		t.Errorf("Write() = %v; want %v", err, ResponseCopied)
+	}
+	//the copy keeps the context of its request, which is done
+	if v := cp.Value(ctxKey("user")); v != "1" {
+		t.Errorf("Value(user) = %v; want 1", v)
+	}
+	select {
+	case <-cp.Done():
+	default:
+		t.Error("the copy should be done with its request")
+	}
+	if cp.Err() != context.Canceled {
+		t.Errorf("Err() = %v; want %v", cp.Err(), context.Canceled)
+	}
+}
@@ -1,8 +1,11 @@
 package goil
 
 import (
+	"fmt"
 	"reflect"
+	"regexp"
 	"strconv"
+	"strings"
 )
 
 type Convert func(value string, dTyp reflect.Type) (interface{}, error)
@@ -30,11 +33,74 @@ var convertFunc = map[string]Convert{
 	},
 }
 
+//the alias of the builtin converts used by the constraints of the path params
+var constraintAlias = map[string]string{
+	"int":   "_a2i",
+	"uint":  "_a2u",
+	"float": "_a2f",
+	"bool":  "_a2b",
+}
+
+var strTyp = reflect.TypeOf("")
+
+//constraint checks the value of the path param, the constraint is declared after the param name:
+//	/users/:id<int>
+//	/files/:name<regex([a-z]+\.png)>
+//the builtin constraints are int, uint, float, bool and regex,
+//and the Convert registered by RegisterConvert can be used as a constraint by its name
+type constraint struct {
+	spec string
+	conv Convert
+	re   *regexp.Regexp
+}
+
+func newConstraint(conf *Config, spec string) *constraint {
+	if spec == "" {
+		return nil
+	}
+	c := &constraint{
+		spec: spec,
+	}
+	if strings.HasPrefix(spec, "regex(") && strings.HasSuffix(spec, ")") {
+		c.re = regexp.MustCompile("^(?:" + spec[len("regex("):len(spec)-1] + ")$")
+		return c
+	}
+	name := spec
+	if alias, exists := constraintAlias[name]; exists {
+		name = alias
+	}
+	conv, exists := conf.convert(name)
+	assert1(exists, fmt.Sprintf("unsupported constraint: %s", spec))
+	c.conv = conv
+	return c
+}
+
+//check returns the typed value if the value satisfies the constraint
+func (c *constraint) check(value string) (interface{}, bool) {
+	if c.re != nil {
+		return value, c.re.MatchString(value)
+	}
+	typed, err := c.conv(value, strTyp)
+	if err != nil {
+		return nil, false
+	}
+	return typed, true
+}
+
+//RegisterConvert registers a convert which is used by the `convert` tag when binding params,
+//it can also be used as the constraint of the path params by the name, such as /users/:id<name>.
+//the convert is shared by all the apps, see WithConvert
 func RegisterConvert(name string, fun Convert) {
-	guard.execSafely(func() {
-		convertFunc[name] = fun
-	})
+	defaultsMu.Lock()
+	convertFunc[name] = fun
+	defaultsMu.Unlock()
+}
 
+//mustConvert returns the convert which must exist, such as the builtin _a2i
+func (conf *Config) mustConvert(name string) Convert {
+	conv, exists := conf.convert(name)
+	assert1(exists, fmt.Sprintf("no convert named %s", name))
+	return conv
 }
 
 type IConvert interface {
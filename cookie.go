@@ -0,0 +1,202 @@
+package goil
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"goil/helper/encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	//the cookie with the name prefixed by HOST_PREFIX is set secure, for the path "/" and without the domain
+	HOST_PREFIX = "__Host-"
+	//the cookie with the name prefixed by SECURE_PREFIX is set secure
+	SECURE_PREFIX = "__Secure-"
+)
+
+var CookieKeysMissing = errors.New("no cookie keys, see WithCookieKeys.")
+var CookieInvalid = errors.New("the cookie is invalid.")
+
+//CookieOptions are the attributes of the cookie set by SetCookieWithOptions,
+//SetSignedCookie and SetEncryptedCookie
+type CookieOptions struct {
+	//"/" is used if it is empty
+	Path   string
+	Domain string
+	//MaxAge<0 means deleting the cookie, zero means no Max-Age
+	MaxAge  int
+	Expires time.Time
+	Secure  bool
+	//HttpOnly hides the cookie from the scripts
+	HttpOnly bool
+	SameSite http.SameSite
+	//Partitioned stores the cookie by the top-level site, the cookie is set secure
+	Partitioned bool
+}
+
+//SetCookieWithOptions sets the cookie with the options, the value is escaped as SetCookie.
+//the prefixes HOST_PREFIX and SECURE_PREFIX of the name are respected
+func (c *Context) SetCookieWithOptions(name, value string, opts CookieOptions) {
+	c.setCookie(name, url.QueryEscape(value), opts)
+}
+
+func (c *Context) setCookie(name, value string, opts CookieOptions) {
+	cookie := &http.Cookie{
+		Name:        name,
+		Value:       value,
+		Path:        opts.Path,
+		Domain:      opts.Domain,
+		MaxAge:      opts.MaxAge,
+		Expires:     opts.Expires,
+		Secure:      opts.Secure || opts.Partitioned,
+		HttpOnly:    opts.HttpOnly,
+		SameSite:    opts.SameSite,
+		Partitioned: opts.Partitioned,
+	}
+	if cookie.Path == "" {
+		cookie.Path = "/"
+	}
+	switch {
+	case strings.HasPrefix(name, HOST_PREFIX):
+		cookie.Secure = true
+		cookie.Path = "/"
+		cookie.Domain = ""
+	case strings.HasPrefix(name, SECURE_PREFIX):
+		cookie.Secure = true
+	}
+	http.SetCookie(c.Response, cookie)
+}
+
+//SetSignedCookie sets the cookie signed by HMAC-SHA256 with the first key of the app,
+//the value is readable by the client but can't be modified, see WithCookieKeys
+func (c *Context) SetSignedCookie(name, value string, opts CookieOptions) error {
+	keys := c.config().cookieKeys()
+	if len(keys) == 0 {
+		return CookieKeysMissing
+	}
+	payload, err := base64.URLEncoding([]byte(value))
+	if err != nil {
+		return err
+	}
+	c.setCookie(name, signValue(keys, name, string(payload)), opts)
+	return nil
+}
+
+//GetSignedCookie returns the value of the cookie set by SetSignedCookie,
+//the signature is verified by all keys of the app, CookieInvalid is returned if it fails
+func (c *Context) GetSignedCookie(name string) (string, error) {
+	cookie, err := c.Request.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	payload, ok := verifyValue(c.config().cookieKeys(), name, cookie.Value)
+	if !ok {
+		return "", CookieInvalid
+	}
+	value, err := base64.URLDecoding([]byte(payload))
+	if err != nil {
+		return "", CookieInvalid
+	}
+	return string(value), nil
+}
+
+//SetEncryptedCookie sets the cookie encrypted by AES-256-GCM with the first key of the app,
+//the value can't be read or modified by the client, see WithCookieKeys
+func (c *Context) SetEncryptedCookie(name, value string, opts CookieOptions) error {
+	keys := c.config().cookieKeys()
+	if len(keys) == 0 {
+		return CookieKeysMissing
+	}
+	aead, err := cookieAEAD(keys[0])
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(value)+aead.Overhead())
+	if _, err = rand.Read(nonce); err != nil {
+		return err
+	}
+	//the name is authenticated, so the cookie can't be moved to another name
+	sealed := aead.Seal(nonce, nonce, []byte(value), []byte(name))
+	payload, err := base64.URLEncoding(sealed)
+	if err != nil {
+		return err
+	}
+	c.setCookie(name, string(payload), opts)
+	return nil
+}
+
+//GetEncryptedCookie returns the value of the cookie set by SetEncryptedCookie,
+//it is decrypted by all keys of the app, CookieInvalid is returned if it fails
+func (c *Context) GetEncryptedCookie(name string) (string, error) {
+	cookie, err := c.Request.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.URLDecoding([]byte(cookie.Value))
+	if err != nil {
+		return "", CookieInvalid
+	}
+	for _, key := range c.config().cookieKeys() {
+		aead, err := cookieAEAD(key)
+		if err != nil || len(sealed) < aead.NonceSize() {
+			continue
+		}
+		size := aead.NonceSize()
+		if value, err := aead.Open(nil, sealed[:size], sealed[size:], []byte(name)); err == nil {
+			return string(value), nil
+		}
+	}
+	return "", CookieInvalid
+}
+
+//cookieAEAD returns the AES-256-GCM with the key derived from the cookie key,
+//so the keys of any length can be used for both signing and encrypting
+func cookieAEAD(key []byte) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("goil.cookie.encryption"))
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+//signValue appends the HMAC-SHA256 of the cookie name and the value by the first key to the value
+func signValue(keys [][]byte, name, value string) string {
+	mac, _ := base64.URLEncoding(cookieMAC(keys[0], name, value))
+	return value + "." + string(mac)
+}
+
+//verifyValue returns the value signed by signValue with any of the keys,
+//ok is false if the signature is invalid
+func verifyValue(keys [][]byte, name, signed string) (value string, ok bool) {
+	i := strings.LastIndexByte(signed, '.')
+	if i < 0 {
+		return "", false
+	}
+	mac, err := base64.URLDecoding([]byte(signed[i+1:]))
+	if err != nil {
+		return "", false
+	}
+	for _, key := range keys {
+		if hmac.Equal(mac, cookieMAC(key, name, signed[:i])) {
+			return signed[:i], true
+		}
+	}
+	return "", false
+}
+
+func cookieMAC(key []byte, name, value string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(name))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
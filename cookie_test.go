@@ -0,0 +1,111 @@
+package goil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignedAndEncryptedCookies(t *testing.T) {
+	oldKey, newKey := []byte("old key"), []byte("new key")
+	app := New(WithCookieKeys(oldKey))
+	opts := CookieOptions{HttpOnly: true, SameSite: http.SameSiteStrictMode}
+	app.GET("/set", func(c *Context) {
+		if err := c.SetSignedCookie("user", "Jim; admin=1", opts); err != nil {
+			t.Fatalf("SetSignedCookie: %s", err)
+		}
+		if err := c.SetEncryptedCookie("token", "s3cret", opts); err != nil {
+			t.Fatalf("SetEncryptedCookie: %s", err)
+		}
+		c.SetEncryptedCookie("other", "s3cret", opts)
+	})
+	app.GET("/get", func(c *Context) {
+		user, err1 := c.GetSignedCookie("user")
+		token, err2 := c.GetEncryptedCookie("token")
+		if err1 != nil || err2 != nil {
+			c.Text("invalid")
+			return
+		}
+		c.Text(user + "|" + token)
+	})
+
+	w := serve(app, GET, "/set")
+	cookies := w.Result().Cookies()
+	if len(cookies) != 3 {
+		t.Fatalf("cookies = %v", cookies)
+	}
+	for _, cookie := range cookies {
+		if !cookie.HttpOnly || cookie.SameSite != http.SameSiteStrictMode || cookie.Path != "/" {
+			t.Errorf("the options aren't applied: %v", cookie)
+		}
+	}
+	if strings.Contains(cookies[1].Value, "s3cret") {
+		t.Errorf("the encrypted cookie is readable: %s", cookies[1].Value)
+	}
+
+	get := func(cookies ...*http.Cookie) string {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(GET, "/get", nil)
+		for _, cookie := range cookies {
+			r.AddCookie(cookie)
+		}
+		app.ServeHTTP(w, r)
+		return w.Body.String()
+	}
+	cookies, other := cookies[:2], cookies[2]
+	if body := get(cookies...); body != "Jim; admin=1|s3cret" {
+		t.Errorf("GET /get = %q", body)
+	}
+
+	//the old key still verifies and decrypts after rotating
+	app.conf.CookieKeys = [][]byte{newKey, oldKey}
+	if body := get(cookies...); body != "Jim; admin=1|s3cret" {
+		t.Errorf("GET /get after rotating = %q", body)
+	}
+	app.conf.CookieKeys = [][]byte{newKey}
+	if body := get(cookies...); body != "invalid" {
+		t.Errorf("GET /get with the removed key = %q", body)
+	}
+	app.conf.CookieKeys = [][]byte{oldKey}
+
+	//tampered or moved cookies
+	tampered := *cookies[0]
+	tampered.Value = "QWRtaW4=" + tampered.Value[strings.LastIndexByte(tampered.Value, '.'):]
+	if body := get(&tampered, cookies[1]); body != "invalid" {
+		t.Errorf("the tampered cookie is accepted")
+	}
+	moved := *other
+	moved.Name = "token"
+	if body := get(cookies[0], &moved); body != "invalid" {
+		t.Errorf("the moved cookie is accepted")
+	}
+
+	noKeys := New()
+	noKeys.GET("/", func(c *Context) {
+		if err := c.SetSignedCookie("user", "Jim", opts); err != CookieKeysMissing {
+			t.Errorf("SetSignedCookie without keys = %v", err)
+		}
+	})
+	serve(noKeys, GET, "/")
+}
+
+func TestCookieOptions(t *testing.T) {
+	app := New()
+	expires := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	app.GET("/", func(c *Context) {
+		c.SetCookieWithOptions(HOST_PREFIX+"id", "a b", CookieOptions{Path: "/admin", Domain: "example.com"})
+		c.SetCookieWithOptions(SECURE_PREFIX+"id", "1", CookieOptions{})
+		c.SetCookieWithOptions("embed", "1", CookieOptions{Partitioned: true, SameSite: http.SameSiteNoneMode, Expires: expires})
+	})
+	header := serve(app, GET, "/").Header()["Set-Cookie"]
+	want := []string{
+		"__Host-id=a+b; Path=/; Secure",
+		"__Secure-id=1; Path=/; Secure",
+		"embed=1; Path=/; Expires=Tue, 01 Jan 2030 00:00:00 GMT; Secure; SameSite=None; Partitioned",
+	}
+	if strings.Join(header, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Set-Cookie = %q; want %q", header, want)
+	}
+}
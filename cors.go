@@ -0,0 +1,190 @@
+package goil
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ORIGIN                           = "Origin"
+	ACCESS_CONTROL_ALLOW_ORIGIN      = "Access-Control-Allow-Origin"
+	ACCESS_CONTROL_ALLOW_METHODS     = "Access-Control-Allow-Methods"
+	ACCESS_CONTROL_ALLOW_HEADERS     = "Access-Control-Allow-Headers"
+	ACCESS_CONTROL_ALLOW_CREDENTIALS = "Access-Control-Allow-Credentials"
+	ACCESS_CONTROL_EXPOSE_HEADERS    = "Access-Control-Expose-Headers"
+	ACCESS_CONTROL_MAX_AGE           = "Access-Control-Max-Age"
+	ACCESS_CONTROL_REQUEST_METHOD    = "Access-Control-Request-Method"
+	ACCESS_CONTROL_REQUEST_HEADERS   = "Access-Control-Request-Headers"
+)
+
+//CORSConfig configures the middleware created by CORS
+type CORSConfig struct {
+	//the origins allowed, any origin is allowed if it is empty or contains "*".
+	//the origin can contain the wildcards such as "https://*.example.com" and "http://localhost:*",
+	//or be the regular expression like the path constraint: "regex(^https://(www|api)\.example\.com$)"
+	AllowOrigins []string
+	//AllowOriginFunc decides the origins which don't match the AllowOrigins
+	AllowOriginFunc func(origin string) bool
+	//the methods answered to the preflight requests,
+	//the methods registered for the requested path are used if it is empty
+	AllowMethods []string
+	//the headers answered to the preflight requests,
+	//the Access-Control-Request-Headers is echoed if it is empty
+	AllowHeaders []string
+	//the headers the browser can expose to the scripts
+	ExposeHeaders []string
+	//AllowCredentials allows the cookies and the authorization,
+	//the origin is echoed instead of "*" then
+	AllowCredentials bool
+	//how long the result of the preflight request can be cached, it isn't sent if it is zero
+	MaxAge time.Duration
+}
+
+//the compiled AllowOrigins
+type originMatcher struct {
+	any      bool
+	origins  map[string]bool
+	patterns []*regexp.Regexp
+	fn       func(origin string) bool
+}
+
+func newOriginMatcher(conf CORSConfig) *originMatcher {
+	m := &originMatcher{
+		any:     len(conf.AllowOrigins) == 0 && conf.AllowOriginFunc == nil,
+		origins: make(map[string]bool),
+		fn:      conf.AllowOriginFunc,
+	}
+	for _, origin := range conf.AllowOrigins {
+		switch {
+		case origin == "*":
+			m.any = true
+		case strings.HasPrefix(origin, "regex(") && strings.HasSuffix(origin, ")"):
+			m.patterns = append(m.patterns, regexp.MustCompile("^(?:"+origin[len("regex("):len(origin)-1]+")$"))
+		case strings.Contains(origin, "*"):
+			//the wildcard matches a part of the host or the port
+			exp := strings.Replace(regexp.QuoteMeta(strings.ToLower(origin)), `\*`, `[a-z0-9.-]+`, -1)
+			m.patterns = append(m.patterns, regexp.MustCompile("^"+exp+"$"))
+		default:
+			m.origins[strings.ToLower(origin)] = true
+		}
+	}
+	return m
+}
+
+func (m *originMatcher) match(origin string) bool {
+	if m.any {
+		return true
+	}
+	lower := strings.ToLower(origin)
+	if m.origins[lower] {
+		return true
+	}
+	for _, re := range m.patterns {
+		if re.MatchString(lower) {
+			return true
+		}
+	}
+	return m.fn != nil && m.fn(origin)
+}
+
+//CORS returns the middleware handling the cross-origin requests,
+//the preflight requests are answered with 204 and the rest handlers are skipped,
+//so the routes which only register GET or POST can be requested cross-origin as well.
+//it should be registered by App.Use to answer the preflight requests for all paths,
+//the preflight requests of the disallowed origins or methods get 403
+func CORS(conf CORSConfig) HandlerFunc {
+	origins := newOriginMatcher(conf)
+	allowMethods := strings.Join(conf.AllowMethods, ", ")
+	allowHeaders := strings.Join(conf.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(conf.ExposeHeaders, ", ")
+	maxAge := ""
+	if conf.MaxAge > 0 {
+		maxAge = strconv.FormatInt(int64(conf.MaxAge/time.Second), 10)
+	}
+
+	return func(c *Context) {
+		origin := c.Header(ORIGIN)
+		header := c.Response.Header()
+		if !origins.any || conf.AllowCredentials {
+			addVary(header, ORIGIN)
+		}
+		if origin == "" {
+			//not a cross-origin request
+			return
+		}
+
+		preflight := c.Request.Method == OPTIONS && c.Header(ACCESS_CONTROL_REQUEST_METHOD) != ""
+		if !origins.match(origin) {
+			if preflight {
+				c.Status(http.StatusForbidden)
+				c.Abort()
+			}
+			return
+		}
+
+		if origins.any && !conf.AllowCredentials {
+			header.Set(ACCESS_CONTROL_ALLOW_ORIGIN, "*")
+		} else {
+			header.Set(ACCESS_CONTROL_ALLOW_ORIGIN, origin)
+		}
+		if conf.AllowCredentials {
+			header.Set(ACCESS_CONTROL_ALLOW_CREDENTIALS, "true")
+		}
+		if !preflight {
+			if exposeHeaders != "" {
+				header.Set(ACCESS_CONTROL_EXPOSE_HEADERS, exposeHeaders)
+			}
+			return
+		}
+
+		addVary(header, ACCESS_CONTROL_REQUEST_METHOD)
+		addVary(header, ACCESS_CONTROL_REQUEST_HEADERS)
+		method := strings.ToUpper(c.Header(ACCESS_CONTROL_REQUEST_METHOD))
+		methods := conf.AllowMethods
+		if len(methods) == 0 {
+			methods = c.allowedMethods()
+		}
+		if !containsMethod(methods, method) {
+			c.Status(http.StatusForbidden)
+			c.Abort()
+			return
+		}
+
+		if allowMethods != "" {
+			header.Set(ACCESS_CONTROL_ALLOW_METHODS, allowMethods)
+		} else {
+			header.Set(ACCESS_CONTROL_ALLOW_METHODS, strings.Join(methods, ", "))
+		}
+		if allowHeaders != "" {
+			header.Set(ACCESS_CONTROL_ALLOW_HEADERS, allowHeaders)
+		} else if reqHeaders := c.Header(ACCESS_CONTROL_REQUEST_HEADERS); reqHeaders != "" {
+			header.Set(ACCESS_CONTROL_ALLOW_HEADERS, reqHeaders)
+		}
+		if maxAge != "" {
+			header.Set(ACCESS_CONTROL_MAX_AGE, maxAge)
+		}
+		c.Status(http.StatusNoContent)
+		c.Abort()
+	}
+}
+
+//allowedMethods returns the methods registered for the path of the request
+func (c *Context) allowedMethods() []string {
+	if c.app == nil {
+		return nil
+	}
+	rt, _ := c.app.matchHost(c.Request.Host)
+	return rt.allowed(c.Request.URL.Path, "")
+}
+
+func containsMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
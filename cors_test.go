@@ -0,0 +1,97 @@
+package goil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	app := New()
+	app.Use(CORS(CORSConfig{
+		AllowOrigins:     []string{"https://example.com", "https://*.example.org", "regex(http://localhost:\\d+)"},
+		ExposeHeaders:    []string{"X-Total"},
+		AllowCredentials: true,
+		MaxAge:           time.Hour,
+	}))
+	app.GET("/users/:id", func(c *Context) { c.Text("user") })
+	app.POST("/users/:id", func(c *Context) { c.Text("created") })
+
+	request := func(method, path, origin, reqMethod string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, nil)
+		if origin != "" {
+			r.Header.Set(ORIGIN, origin)
+		}
+		if reqMethod != "" {
+			r.Header.Set(ACCESS_CONTROL_REQUEST_METHOD, reqMethod)
+			r.Header.Set(ACCESS_CONTROL_REQUEST_HEADERS, "X-Token")
+		}
+		app.ServeHTTP(w, r)
+		return w
+	}
+
+	//the preflight request of the route which only registers GET and POST
+	w := request(OPTIONS, "/users/1", "https://api.example.org", POST)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("preflight: code = %d; want %d", w.Code, http.StatusNoContent)
+	}
+	header := w.Header()
+	if v := header.Get(ACCESS_CONTROL_ALLOW_ORIGIN); v != "https://api.example.org" {
+		t.Errorf("preflight: allow origin = %q", v)
+	}
+	if v := header.Get(ACCESS_CONTROL_ALLOW_METHODS); v != "GET, POST" {
+		t.Errorf("preflight: allow methods = %q", v)
+	}
+	if v := header.Get(ACCESS_CONTROL_ALLOW_HEADERS); v != "X-Token" {
+		t.Errorf("preflight: allow headers = %q", v)
+	}
+	if v := header.Get(ACCESS_CONTROL_ALLOW_CREDENTIALS); v != "true" {
+		t.Errorf("preflight: allow credentials = %q", v)
+	}
+	if v := header.Get(ACCESS_CONTROL_MAX_AGE); v != "3600" {
+		t.Errorf("preflight: max age = %q", v)
+	}
+
+	//the disallowed method and origin
+	if w = request(OPTIONS, "/users/1", "https://example.com", DELETE); w.Code != http.StatusForbidden {
+		t.Errorf("preflight DELETE: code = %d; want %d", w.Code, http.StatusForbidden)
+	}
+	if w = request(OPTIONS, "/users/1", "https://evil.com", GET); w.Code != http.StatusForbidden {
+		t.Errorf("preflight from evil.com: code = %d; want %d", w.Code, http.StatusForbidden)
+	}
+	if w = request(OPTIONS, "/users/1", "https://example.org.evil.com", GET); w.Code != http.StatusForbidden {
+		t.Errorf("preflight from example.org.evil.com: code = %d; want %d", w.Code, http.StatusForbidden)
+	}
+
+	//the simple requests
+	w = request(GET, "/users/1", "http://localhost:8080", "")
+	if w.Body.String() != "user" || w.Header().Get(ACCESS_CONTROL_ALLOW_ORIGIN) != "http://localhost:8080" {
+		t.Errorf("GET from localhost: %q %v", w.Body.String(), w.Header())
+	}
+	if v := w.Header().Get(ACCESS_CONTROL_EXPOSE_HEADERS); v != "X-Total" {
+		t.Errorf("GET from localhost: expose headers = %q", v)
+	}
+	if v := w.Header().Get(VARY); v != ORIGIN {
+		t.Errorf("GET from localhost: vary = %q", v)
+	}
+	w = request(GET, "/users/1", "https://evil.com", "")
+	if w.Body.String() != "user" || w.Header().Get(ACCESS_CONTROL_ALLOW_ORIGIN) != "" {
+		t.Errorf("GET from evil.com: %q %v", w.Body.String(), w.Header())
+	}
+
+	//the plain OPTIONS isn't the preflight request
+	if w = request(OPTIONS, "/users/1", "https://example.com", ""); w.Header().Get(ALLOW) != "GET, OPTIONS, POST" {
+		t.Errorf("OPTIONS: allow = %q", w.Header().Get(ALLOW))
+	}
+
+	//any origin
+	app = New()
+	app.Use(CORS(CORSConfig{}))
+	app.PUT("/files", func(c *Context) {})
+	w = request(OPTIONS, "/files", "https://any.com", PUT)
+	if w.Code != http.StatusNoContent || w.Header().Get(ACCESS_CONTROL_ALLOW_ORIGIN) != "*" {
+		t.Errorf("any origin: %d %v", w.Code, w.Header())
+	}
+}
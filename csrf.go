@@ -0,0 +1,218 @@
+package goil
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const (
+	X_CSRF_TOKEN        = "X-CSRF-Token"
+	DEFAULT_CSRF_FIELD  = "csrf_token"
+	DEFAULT_CSRF_COOKIE = "_csrf"
+)
+
+//the patterns of CSRF
+const (
+	//CSRF_COOKIE is the double submit cookie pattern, the token is stored in the cookie
+	CSRF_COOKIE = iota
+	//CSRF_SESSION is the synchronizer token pattern, the token is stored in the session,
+	//so the Sessions middleware should be used before
+	CSRF_SESSION
+)
+
+//the route metadata key set by CSRFExempt
+const META_CSRF_EXEMPT = "csrfExempt"
+
+//CSRFTokenInvalid is recorded by CSRF when the token is missing or invalid,
+//the error recorded is a copy which can be checked by errors.Is
+var CSRFTokenInvalid = NewHTTPError(http.StatusForbidden, "the csrf token is invalid.")
+
+const (
+	//the key of the csrf state stored in the context
+	csrfKey = "goil.csrf"
+	//the key of the token in the session
+	csrfSessionKey = "_goil.csrf"
+	csrfTokenSize  = 32
+)
+
+//CSRFConfig configures the middleware created by CSRF
+type CSRFConfig struct {
+	//CSRF_COOKIE or CSRF_SESSION
+	Pattern int
+	//the header of the token, X_CSRF_TOKEN is used if it is empty
+	Header string
+	//the form field and the query param of the token, DEFAULT_CSRF_FIELD is used if it is empty
+	Field string
+	//the cookie of CSRF_COOKIE, DEFAULT_CSRF_COOKIE is used if it is empty,
+	//the name prefixed by HOST_PREFIX is recommended over HTTPS
+	CookieName string
+	//the attributes of the cookie, SameSite is Lax if it is zero.
+	//HttpOnly should be false if the scripts read the token from the cookie
+	Cookie CookieOptions
+	//Skip exempts the requests besides the safe methods and the routes with CSRFExempt
+	Skip func(c *Context) bool
+}
+
+//the token of the request
+type csrfState struct {
+	field string
+	token []byte
+}
+
+//CSRFExempt exempts the route from the CSRF middleware, such as the webhooks
+func CSRFExempt() RouteOption {
+	return Meta(META_CSRF_EXEMPT, true)
+}
+
+//CSRF returns the middleware protecting the unsafe methods from the cross-site request forgery,
+//the token is read from the header, the form field or the query, and CSRFTokenInvalid is recorded
+//if it doesn't match. the token is got by Context.CSRFToken, or the template funcs of HtmlTemp:
+//	<form method="post">{{csrfField}}...</form>
+//	<meta name="csrf-token" content="{{csrfToken}}">
+func CSRF(conf CSRFConfig) HandlerFunc {
+	if conf.Header == "" {
+		conf.Header = X_CSRF_TOKEN
+	}
+	if conf.Field == "" {
+		conf.Field = DEFAULT_CSRF_FIELD
+	}
+	if conf.CookieName == "" {
+		conf.CookieName = DEFAULT_CSRF_COOKIE
+	}
+	if conf.Cookie.SameSite == 0 {
+		conf.Cookie.SameSite = http.SameSiteLaxMode
+	}
+	return func(c *Context) {
+		token := conf.token(c)
+		c.Set(csrfKey, &csrfState{field: conf.Field, token: token})
+
+		switch c.Request.Method {
+		case GET, HEAD, OPTIONS, TRACE:
+			return
+		}
+		if exempt, _ := c.Route().Get(META_CSRF_EXEMPT); exempt == true {
+			return
+		}
+		if conf.Skip != nil && conf.Skip(c) {
+			return
+		}
+		sent, err := conf.sent(c)
+		if err != nil {
+			c.AbortWithError(err)
+			return
+		}
+		if !verifyCSRFToken(token, sent) {
+			c.AbortWithError(CSRFTokenInvalid.copy())
+		}
+	}
+}
+
+//token returns the token stored, a new token is generated and stored if it doesn't exist
+func (conf *CSRFConfig) token(c *Context) []byte {
+	if conf.Pattern == CSRF_SESSION {
+		s := c.Session()
+		stored, _ := s.GetDef(csrfSessionKey, "").(string)
+		if token := decodeCSRFToken(stored); len(token) == csrfTokenSize {
+			return token
+		}
+		token := newCSRFToken()
+		s.Set(csrfSessionKey, base64.RawURLEncoding.EncodeToString(token))
+		return token
+	}
+
+	if cookie, err := c.Request.Cookie(conf.CookieName); err == nil {
+		if token := decodeCSRFToken(cookie.Value); len(token) == csrfTokenSize {
+			return token
+		}
+	}
+	token := newCSRFToken()
+	c.setCookie(conf.CookieName, base64.RawURLEncoding.EncodeToString(token), conf.Cookie)
+	return token
+}
+
+//sent returns the token sent by the header, the form field or the query,
+//the error is RequestTooLarge if the multipart form is over the limits of the app
+func (conf *CSRFConfig) sent(c *Context) (string, error) {
+	if token := c.Header(conf.Header); token != "" {
+		return token, nil
+	}
+	if strings.HasPrefix(c.Header(CONTENT_TYPE), MIME_MULT_POST) {
+		if err := c.parseMultipart(); errors.Is(err, RequestTooLarge) {
+			return "", err
+		}
+	}
+	if token := c.Request.PostFormValue(conf.Field); token != "" {
+		return token, nil
+	}
+	return c.Query(conf.Field), nil
+}
+
+//CSRFToken returns the token for the forms and the scripts, it is masked by the random bytes
+//every time so it can't be guessed from the compressed responses. it is empty if the CSRF middleware isn't used
+func (c *Context) CSRFToken() string {
+	state, ok := c.GetDef(csrfKey, nil).(*csrfState)
+	if !ok {
+		return ""
+	}
+	return maskCSRFToken(state.token)
+}
+
+//csrfForm returns the form field and the token for the template func csrfField
+func (c *Context) csrfForm() (field, token string) {
+	state, ok := c.GetDef(csrfKey, nil).(*csrfState)
+	if !ok {
+		return "", ""
+	}
+	return state.field, maskCSRFToken(state.token)
+}
+
+func newCSRFToken() []byte {
+	token := make([]byte, csrfTokenSize)
+	if _, err := rand.Read(token); err != nil {
+		panic(err)
+	}
+	return token
+}
+
+func decodeCSRFToken(s string) []byte {
+	token, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil
+	}
+	return token
+}
+
+//maskCSRFToken returns the random pad and the token xor the pad
+func maskCSRFToken(token []byte) string {
+	masked := make([]byte, 2*csrfTokenSize)
+	pad := masked[:csrfTokenSize]
+	if _, err := rand.Read(pad); err != nil {
+		panic(err)
+	}
+	for i, b := range token {
+		masked[csrfTokenSize+i] = b ^ pad[i]
+	}
+	return base64.RawURLEncoding.EncodeToString(masked)
+}
+
+//verifyCSRFToken compares the token sent with the token stored,
+//the token sent can be masked by CSRFToken or the raw one in the cookie
+func verifyCSRFToken(token []byte, sent string) bool {
+	raw := decodeCSRFToken(sent)
+	switch len(raw) {
+	case csrfTokenSize:
+	case 2 * csrfTokenSize:
+		pad := raw[:csrfTokenSize]
+		for i := range pad {
+			raw[csrfTokenSize+i] ^= pad[i]
+		}
+		raw = raw[csrfTokenSize:]
+	default:
+		return false
+	}
+	return subtle.ConstantTimeCompare(token, raw) == 1
+}
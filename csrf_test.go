@@ -0,0 +1,150 @@
+package goil
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCSRFCookie(t *testing.T) {
+	html := NewHtmlTemp()
+	html.T.New("form").Parse(`<form method="post">{{csrfField}}</form><meta content="{{csrfToken}}">`)
+	app := New(WithHtmlRender(html))
+	app.Use(CSRF(CSRFConfig{}))
+	ok := func(c *Context) { c.Text("ok") }
+	app.GET("/form", func(c *Context) { c.Html("form", nil) })
+	app.POST("/submit", ok)
+	app.POST("/hook", ok).With(CSRFExempt())
+
+	w := serve(app, GET, "/form")
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != DEFAULT_CSRF_COOKIE {
+		t.Fatalf("cookies = %v", cookies)
+	}
+	m := regexp.MustCompile(`^<form method="post"><input type="hidden" name="csrf_token" value="([\w-]+)"></form><meta content="([\w-]+)">$`).
+		FindStringSubmatch(w.Body.String())
+	if m == nil {
+		t.Fatalf("body = %q", w.Body.String())
+	}
+	field, meta := m[1], m[2]
+	if field == cookies[0].Value {
+		t.Errorf("the token isn't masked")
+	}
+
+	post := func(path, token, how string, cookie *http.Cookie) int {
+		var r *http.Request
+		switch how {
+		case "form":
+			r = httptest.NewRequest(POST, path, strings.NewReader(url.Values{DEFAULT_CSRF_FIELD: {token}}.Encode()))
+			r.Header.Set(CONTENT_TYPE, MIME_POST)
+		case "query":
+			r = httptest.NewRequest(POST, path+"?"+DEFAULT_CSRF_FIELD+"="+token, nil)
+		default:
+			r = httptest.NewRequest(POST, path, nil)
+			if token != "" {
+				r.Header.Set(X_CSRF_TOKEN, token)
+			}
+		}
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	cases := []struct {
+		token  string
+		how    string
+		cookie *http.Cookie
+		code   int
+	}{
+		{field, "form", cookies[0], http.StatusOK},
+		{meta, "header", cookies[0], http.StatusOK},
+		{field, "query", cookies[0], http.StatusOK},
+		//the raw token read from the cookie by the scripts
+		{cookies[0].Value, "header", cookies[0], http.StatusOK},
+		{"", "header", cookies[0], http.StatusForbidden},
+		{field, "form", nil, http.StatusForbidden},
+		{field, "form", &http.Cookie{Name: DEFAULT_CSRF_COOKIE, Value: cookies[0].Value[1:] + "A"}, http.StatusForbidden},
+	}
+	for i, cs := range cases {
+		if code := post("/submit", cs.token, cs.how, cs.cookie); code != cs.code {
+			t.Errorf("case %d: code = %d; want %d", i, code, cs.code)
+		}
+	}
+	if code := post("/hook", "", "header", nil); code != http.StatusOK {
+		t.Errorf("the exempt route: code = %d", code)
+	}
+
+	//the multipart form is parsed within the limits of the app
+	limits := []struct {
+		memory, diskLimit int64
+		size              int
+		code              int
+	}{
+		{64, 384, 16, http.StatusOK},
+		{64, 384, 100 * 1024, http.StatusRequestEntityTooLarge},
+		//the limit within the part headers
+		{16, 256, 100 * 1024, http.StatusRequestEntityTooLarge},
+	}
+	for i, l := range limits {
+		app := New(WithMultipart(l.memory, l.diskLimit))
+		app.Use(CSRF(CSRFConfig{}))
+		app.POST("/submit", ok)
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		mw.WriteField(DEFAULT_CSRF_FIELD, field)
+		mw.WriteField("text", strings.Repeat("a", l.size))
+		mw.Close()
+		r := httptest.NewRequest(POST, "/submit", &buf)
+		r.Header.Set(CONTENT_TYPE, mw.FormDataContentType())
+		r.AddCookie(cookies[0])
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+		if w.Code != l.code {
+			t.Errorf("multipart %d: code = %d; want %d", i, w.Code, l.code)
+		}
+	}
+}
+
+func TestCSRFSession(t *testing.T) {
+	app := New()
+	app.ErrorHandler = func(c *Context, err error) {
+		//the error is a copy of the shared one
+		if err == error(CSRFTokenInvalid) || !errors.Is(err, CSRFTokenInvalid) {
+			t.Errorf("the error = %#v", err)
+		}
+		DefHTTPErrorHandler(c, err)
+	}
+	app.Use(Sessions(SessionConfig{Secret: []byte("secret")}), CSRF(CSRFConfig{Pattern: CSRF_SESSION}))
+	app.GET("/token", func(c *Context) { c.Text(c.CSRFToken()) })
+	app.DELETE("/posts/:id", func(c *Context) { c.Text("deleted") })
+
+	w := serve(app, GET, "/token")
+	token := w.Body.String()
+	session := w.Result().Cookies()[0]
+
+	del := func(token string, cookie *http.Cookie) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(DELETE, "/posts/1", nil)
+		r.Header.Set(X_CSRF_TOKEN, token)
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+		app.ServeHTTP(w, r)
+		return w.Code
+	}
+	if code := del(token, session); code != http.StatusOK {
+		t.Errorf("DELETE with the token: code = %d", code)
+	}
+	if code := del(token, nil); code != http.StatusForbidden {
+		t.Errorf("DELETE without the session: code = %d", code)
+	}
+}
@@ -11,6 +11,10 @@ const (
 	CONTENT_TYPE     = "Content-Type"
 	CONTENT_ENCODING = "Content-Encoding"
 	ACCEPT           = "Accept"
+	ALLOW            = "Allow"
+	ACCEPT_ENCODING  = "Accept-Encoding"
+	CONTENT_LENGTH   = "Content-Length"
+	VARY             = "Vary"
 )
 
 //TODO:the prefix can config
@@ -57,7 +61,8 @@ const (
 )
 
 var (
-	NoHandlers = errors.New("no handlers.")
+	NoHandlers     = errors.New("no handlers.")
+	ResponseCopied = errors.New("can't write the response of the copied context.")
 )
 
 const (
@@ -69,6 +74,8 @@ type M map[string]interface{}
 type Param struct {
 	Key   string
 	Value string
+	//the value converted by the constraint of the param
+	typed interface{}
 }
 
 type Params []Param
@@ -89,6 +96,26 @@ func (p *Params) set(key string, value string) {
 	})
 }
 
+func (p *Params) setTyped(key string, value string, typed interface{}) {
+	*p = append(*p, Param{
+		Key:   key,
+		Value: value,
+		typed: typed,
+	})
+}
+
+func (p *Params) getTyped(key string) (interface{}, bool) {
+	for _, kv := range *p {
+		if kv.Key == key {
+			if kv.typed != nil {
+				return kv.typed, true
+			}
+			return kv.Value, true
+		}
+	}
+	return nil, false
+}
+
 type concurrentMap struct {
 	sync.RWMutex
 	values map[interface{}]interface{}
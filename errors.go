@@ -0,0 +1,176 @@
+package goil
+
+import (
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+//HTTPError is the error with the http status code, the Message is sent to the client
+//and the Internal is only logged:
+//	c.AbortWithError(goil.NewHTTPError(http.StatusForbidden).SetInternal(err))
+type HTTPError struct {
+	Code     int
+	Message  string
+	Internal error
+	//the shared error copied from, see Is
+	from *HTTPError
+}
+
+//NewHTTPError creates the HTTPError, the status text of the code is used if the message is empty
+func NewHTTPError(code int, message ...string) *HTTPError {
+	e := &HTTPError{
+		Code:    code,
+		Message: http.StatusText(code),
+	}
+	if len(message) > 0 {
+		e.Message = message[0]
+	}
+	return e
+}
+
+//SetInternal sets the internal error
+func (e *HTTPError) SetInternal(err error) *HTTPError {
+	e.Internal = err
+	return e
+}
+
+func (e *HTTPError) Error() string {
+	if e.Internal == nil {
+		return fmt.Sprintf("code=%d, message=%s", e.Code, e.Message)
+	}
+	return fmt.Sprintf("code=%d, message=%s, internal=%s", e.Code, e.Message, e.Internal)
+}
+
+//Unwrap returns the internal error
+func (e *HTTPError) Unwrap() error {
+	return e.Internal
+}
+
+//Is reports whether the error is copied from the target, so the errors recorded by the middlewares
+//can be checked by the shared ones, such as errors.Is(err, goil.RequestTooLarge)
+func (e *HTTPError) Is(target error) bool {
+	return e.from != nil && target == error(e.from)
+}
+
+//copy returns the copy of the shared error for the request,
+//so modifying it doesn't change the shared one
+func (e *HTTPError) copy() *HTTPError {
+	return &HTTPError{
+		Code:     e.Code,
+		Message:  e.Message,
+		Internal: e.Internal,
+		from:     e,
+	}
+}
+
+//Error records the error of the request, the App.ErrorHandler is called with the last error
+//once the handlers are done, before the middlewares go on after Next. the nil error is ignored
+func (c *Context) Error(err error) {
+	if err == nil {
+		return
+	}
+	c.errors = append(c.errors, err)
+	c.errHandled = false
+}
+
+//Errors returns the errors recorded by Error
+func (c *Context) Errors() []error {
+	return c.errors
+}
+
+//AbortWithError records the error and stops executing the rest handlers
+func (c *Context) AbortWithError(err error) {
+	c.Error(err)
+	c.Abort()
+}
+
+//Written reports whether the header of the response has been sent
+func (c *Context) Written() bool {
+	return c.Response.Size() != nowriten
+}
+
+//the body of the error rendered by the DefHTTPErrorHandler
+type errorBody struct {
+	XMLName xml.Name `json:"-" xml:"error"`
+	Code    int      `json:"code" xml:"code"`
+	Message string   `json:"message" xml:"message"`
+}
+
+//DefHTTPErrorHandler is the default App.ErrorHandler, it renders the error by the Accept header
+//as json, xml or text. the status is the Code of HTTPError, 400 for the binding errors and 500 for the others,
+//the 5xx errors are logged, and nothing is written if the response has been written
+func DefHTTPErrorHandler(c *Context, err error) {
+	code := http.StatusInternalServerError
+	message := http.StatusText(code)
+	switch e := err.(type) {
+	case *HTTPError:
+		code = e.Code
+		message = e.Message
+	case *ValidatorError:
+		code = http.StatusBadRequest
+		message = e.Error()
+	default:
+		if err == ParamsBindingError || err == ParamsInvalidError || err == ParamsValidateError {
+			code = http.StatusBadRequest
+			message = err.Error()
+		}
+	}
+	if code >= http.StatusInternalServerError {
+		c.Logger().Errorf("when handling request %s %s: %s", c.Request.Method, c.Request.URL.Path, err)
+	}
+	if c.Written() {
+		return
+	}
+
+	c.Status(code)
+	body := errorBody{
+		Code:    code,
+		Message: message,
+	}
+	switch acceptedFormat(c.Header(ACCEPT)) {
+	case MIME_JSON:
+		c.JSON(body)
+	case MIME_XML:
+		c.Xml(body)
+	default:
+		c.Text(message)
+	}
+}
+
+//acceptedFormat returns MIME_JSON, MIME_XML or MIME_TEXT preferred by the Accept header
+func acceptedFormat(accept string) string {
+	for _, part := range strings.Split(accept, ",") {
+		mt, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mt {
+		case MIME_JSON:
+			return MIME_JSON
+		case MIME_XML, "application/xml":
+			return MIME_XML
+		case MIME_TEXT, MIME_HTML:
+			return MIME_TEXT
+		}
+	}
+	return MIME_TEXT
+}
+
+//handleError calls the error handler of the app with the last error if it isn't handled
+func (c *Context) handleError() {
+	if len(c.errors) == 0 || c.errHandled {
+		return
+	}
+	c.errHandled = true
+	var handler ErrorHandler
+	if c.app != nil {
+		handler = c.app.ErrorHandler
+	}
+	if handler == nil {
+		handler = DefHTTPErrorHandler
+	}
+	handler(c, c.errors[len(c.errors)-1])
+}
@@ -0,0 +1,112 @@
+package goil
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestErrorHandler(t *testing.T) {
+	app := New()
+	app.Use(Recover())
+	app.GET("/forbidden", func(c *Context) {
+		c.AbortWithError(NewHTTPError(http.StatusForbidden, "no access").SetInternal(errors.New("token expired")))
+	}, func(c *Context) {
+		c.Text("unreachable")
+	})
+	app.GET("/panic", func(c *Context) {
+		panic("boom")
+	})
+	app.GET("/written", func(c *Context) {
+		c.Text("partial")
+		panic("boom")
+	})
+	app.GET("/multi", func(c *Context) {
+		c.Error(errors.New("first"))
+		c.Error(nil)
+		c.Error(NewHTTPError(http.StatusConflict))
+		if len(c.Errors()) != 2 {
+			t.Errorf("Errors() = %v", c.Errors())
+		}
+	})
+	x := app.XRouter()
+	x.GET("/bind/:id", func(p *struct {
+		ID int `path:"id" validator:"min(10)"`
+	}) string {
+		return "ok"
+	})
+
+	cases := []struct {
+		path   string
+		accept string
+		code   int
+		body   string
+	}{
+		{"/forbidden", "", http.StatusForbidden, "no access"},
+		{"/forbidden", "application/json", http.StatusForbidden, `{"code":403,"message":"no access"}`},
+		{"/forbidden", "text/html, application/xml;q=0.9", http.StatusForbidden, "no access"},
+		{"/forbidden", "application/xml", http.StatusForbidden, "<error><code>403</code><message>no access</message></error>"},
+		{"/panic", "", http.StatusInternalServerError, "Internal Server Error"},
+		{"/written", "", http.StatusOK, "partial"},
+		{"/multi", "", http.StatusConflict, "Conflict"},
+		//XRouter keeps its own ErrorHandler
+		{"/bind/1", "", http.StatusInternalServerError, "when exec validate"},
+	}
+	for _, cs := range cases {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(GET, cs.path, nil)
+		r.Header.Set(ACCEPT, cs.accept)
+		app.ServeHTTP(w, r)
+		if w.Code != cs.code || !strings.Contains(w.Body.String(), cs.body) {
+			t.Errorf("GET %s (%s) = %d %q; want %d %q", cs.path, cs.accept, w.Code, w.Body.String(), cs.code, cs.body)
+		}
+	}
+
+	var handled error
+	app.ErrorHandler = func(c *Context, err error) {
+		handled = err
+		c.Status(http.StatusTeapot)
+	}
+	if w := serve(app, GET, "/multi"); w.Code != http.StatusTeapot {
+		t.Errorf("the custom ErrorHandler isn't used: %d", w.Code)
+	}
+	if e, ok := handled.(*HTTPError); !ok || e.Code != http.StatusConflict {
+		t.Errorf("the ErrorHandler should handle the last error: %v", handled)
+	}
+}
+
+func TestErrorHandledInChain(t *testing.T) {
+	app := New()
+	//the status seen by the outer middlewares after Next, such as the access log
+	var logged int
+	app.Use(func(c *Context) {
+		c.Next()
+		logged = c.Response.Status()
+	}, Recover())
+	app.GET("/error", func(c *Context) {
+		c.Error(NewHTTPError(http.StatusConflict))
+	})
+	app.GET("/abort", func(c *Context) {
+		c.AbortWithError(NewHTTPError(http.StatusTooManyRequests))
+	}, func(c *Context) {})
+	app.GET("/panic", func(c *Context) {
+		panic("boom")
+	})
+
+	cases := []struct {
+		path string
+		code int
+	}{
+		{"/error", http.StatusConflict},
+		{"/abort", http.StatusTooManyRequests},
+		{"/panic", http.StatusInternalServerError},
+	}
+	for _, cs := range cases {
+		logged = 0
+		if w := serve(app, GET, cs.path); w.Code != cs.code || logged != cs.code {
+			t.Errorf("GET %s: code = %d, logged = %d; want %d", cs.path, w.Code, logged, cs.code)
+		}
+	}
+}
@@ -3,23 +3,84 @@ package goil
 import (
 	"goil/logger"
 	"net/http"
+	"sort"
+	"strings"
 	"sync"
 )
 
 type App struct {
 	router      *router
 	contextPool sync.Pool
+
+	//the config set by the options of New
+	conf *Config
+	//stop registering when the app is serving
+	guard *Guard
+
+	//the server lifecycle
+	mu         sync.Mutex
+	server     *serverState
+	onStart    []func()
+	onShutdown []func()
+
+	//handlers for the unmatched requests
+	notFoundHandler  HandlerFunc
+	notMethodHandler HandlerFunc
+
+	//ErrorHandler handles the last error recorded by Context.Error once the handlers are done,
+	//before the middlewares go on after Next. DefHTTPErrorHandler is used if it is nil
+	ErrorHandler ErrorHandler
+
+	//the routers matched by the request host, see App.Host
+	hosts []*hostRouter
+
+	//RedirectTrailingSlash enables automatic redirection if the current route can't be matched
+	//but a handler for the path with (without) the trailing slash exists.
+	//For example if /foo/ is requested but a route only exists for /foo, the
+	//client is redirected to /foo with http status code 301 for GET requests
+	//and 308 for all other request methods.
+	RedirectTrailingSlash bool
+
+	//RedirectFixedPath enables the router to try to fix the current request path, if no
+	//handle is registered for it.
+	//First superfluous path elements like ../ or // are removed by CleanPath.
+	//Afterwards the router does a case-insensitive lookup of the cleaned path.
+	//If a handle can be found for this route, the router makes a redirection
+	//to the corrected path with status code 301 for GET requests and 308 for
+	//all other request methods.
+	RedirectFixedPath bool
+
+	//HandleMethodNotAllowed enables the app to check if another method is allowed for the
+	//current route, if the current request can not be routed.
+	//If this is the case, the request is answered with 'Method Not Allowed'
+	//and HTTP status code 405 and the Allow header.
+	//If no other Method is allowed, the request is delegated to the not found handler.
+	HandleMethodNotAllowed bool
+
+	//HandleOPTIONS enables the app to answer the OPTIONS requests automatically
+	//with the Allow header, if no OPTIONS handler is registered for the path.
+	HandleOPTIONS bool
 }
 
-func New() *App {
-	echoBanner()
-	runmode := RunMode()
-	logger.Printf("[Goil] the app is running in %s mode", runmode)
+//New creates an app configured by the options, the package level settings are used by default
+func New(opts ...Option) *App {
+	conf := newConfig(opts...)
+	l := conf.logger()
+	echoBanner(l)
+	runmode := conf.mode()
+	l.Printf("[Goil] the app is running in %s mode", runmode)
 	if runmode == DBG {
-		logger.Printf("[Goil] you can change the run mode by setting the env: export %s=%s", ENV_KEY, PRD)
+		l.Printf("[Goil] you can change the run mode by setting the env: export %s=%s", ENV_KEY, PRD)
 	}
-	return &App{
-		router: newRouter(),
+	guard := new(Guard)
+	app := &App{
+		router:                 newRouter(conf, guard),
+		conf:                   conf,
+		guard:                  guard,
+		RedirectTrailingSlash:  true,
+		RedirectFixedPath:      true,
+		HandleMethodNotAllowed: true,
+		HandleOPTIONS:          true,
 		contextPool: sync.Pool{
 			New: func() interface{} {
 				return &Context{}
@@ -27,6 +88,17 @@ func New() *App {
 			},
 		},
 	}
+	return app
+}
+
+//Mode returns the run mode of the app
+func (app *App) Mode() string {
+	return app.conf.mode()
+}
+
+//Logger returns the logger of the app
+func (app *App) Logger() logger.ILogger {
+	return app.conf.logger()
 }
 
 //assert App implements http.Handler
@@ -35,26 +107,140 @@ var _ http.Handler = new(App)
 func (app *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path
 	method := r.Method
-	chain, params, tsr := app.router.route(method, path)
+	rt, hostParams := app.matchHost(r.Host)
+	chain, params, tsr, route := rt.route(method, path)
 	if chain != nil {
-		ctx := app.getCtx(w, r)
-		//init the context
-		ctx.chain = chain
-		ctx.params = params
-		ctx.idx = 0
-		ctx.Next()
-		//detach
-		app.putCtx(ctx)
+		app.handle(w, r, chain, joinParams(hostParams, params), route)
 		return
 	}
-	//
-	if tsr {
 
+	if method != CONNECT && path != "/" {
+		//moved permanently for GET, and permanent redirect for other methods
+		//to keep the method and body unchanged
+		code := http.StatusMovedPermanently
+		if method != GET {
+			code = http.StatusPermanentRedirect
+		}
+
+		//the app may be mounted by another app
+		prefix := mountPrefix(r)
+
+		if tsr && app.RedirectTrailingSlash {
+			if len(path) > 1 && path[len(path)-1] == '/' {
+				r.URL.Path = redirectPath(prefix + path[:len(path)-1])
+			} else {
+				r.URL.Path = redirectPath(prefix + path + "/")
+			}
+			r.URL.RawPath = ""
+			http.Redirect(w, r, r.URL.String(), code)
+			return
+		}
+
+		if app.RedirectFixedPath {
+			fixedPath, found := rt.fixPath(method, CleanPath(path), app.RedirectTrailingSlash)
+			//redirecting to the path itself loops forever
+			if found && fixedPath != path {
+				r.URL.Path = redirectPath(prefix + fixedPath)
+				r.URL.RawPath = ""
+				http.Redirect(w, r, r.URL.String(), code)
+				return
+			}
+		}
+	}
+
+	if method == OPTIONS && app.HandleOPTIONS {
+		if allows := rt.allowed(path, OPTIONS); len(allows) > 0 {
+			w.Header().Set(ALLOW, app.allowHeader(allows))
+			app.handle(w, r, combineChain(rt.chain(), OptionsHandler), hostParams, nil)
+			return
+		}
+	} else if app.HandleMethodNotAllowed {
+		if allows := rt.allowed(path, method); len(allows) > 0 {
+			w.Header().Set(ALLOW, app.allowHeader(allows))
+			app.handle(w, r, app.notMethod(rt), hostParams, nil)
+			return
+		}
+	}
+
+	app.handle(w, r, app.notFound(rt), hostParams, nil)
+}
+
+//redirectPath collapses the leading slashes of the location,
+//the browsers take "//evil.com" as the url of another host
+func redirectPath(path string) string {
+	return "/" + strings.TrimLeft(path, "/")
+}
+
+func (app *App) notFound(rt *router) HandlerChain {
+	handler := app.notFoundHandler
+	if handler == nil {
+		handler = NotFoundHandler
+	}
+	return combineChain(rt.chain(), handler)
+}
+
+func (app *App) notMethod(rt *router) HandlerChain {
+	handler := app.notMethodHandler
+	if handler == nil {
+		handler = NotMethodHandler
+	}
+	return combineChain(rt.chain(), handler)
+}
+
+//the OPTIONS is always allowed if the app answers it automatically
+func (app *App) allowHeader(allows []string) string {
+	if app.HandleOPTIONS {
+		i := sort.SearchStrings(allows, OPTIONS)
+		if i == len(allows) || allows[i] != OPTIONS {
+			allows = append(allows, "")
+			copy(allows[i+1:], allows[i:])
+			allows[i] = OPTIONS
+		}
+	}
+	return strings.Join(allows, ", ")
+}
+
+//SetNotFoundHandler replaces the handler for the requests which match no route
+func (app *App) SetNotFoundHandler(handler HandlerFunc) {
+	app.notFoundHandler = handler
+}
+
+//SetNotMethodHandler replaces the handler for the requests whose path is registered
+//by other methods only, the Allow header has been set when the handler is called
+func (app *App) SetNotMethodHandler(handler HandlerFunc) {
+	app.notMethodHandler = handler
+}
+
+func (app *App) handle(w http.ResponseWriter, r *http.Request, chain HandlerChain, params Params, route *Route) {
+	if limit := app.bodyLimit(route); limit > 0 && r.Body != nil && r.Body != http.NoBody {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+	ctx := app.getCtx(w, r)
+	//detach, the temp files and the derived contexts are released even if the handlers panic
+	defer app.putCtx(ctx)
+	//init the context
+	ctx.chain = chain
+	ctx.params = params
+	ctx.route = route
+	ctx.idx = 0
+	ctx.Next()
+	//the errors recorded by the middlewares after Next
+	ctx.handleError()
+	//the status may be set without any body
+	ctx.resp.writeHeaderNow()
+}
+
+//bodyLimit returns the max bytes of the request body for the route
+func (app *App) bodyLimit(route *Route) int64 {
+	if route != nil && route.bodyLimit != 0 {
+		return route.bodyLimit
 	}
+	return app.conf.MaxBodySize
 }
 
 func (app *App) getCtx(w http.ResponseWriter, r *http.Request) *Context {
 	ctx := app.contextPool.Get().(*Context)
+	ctx.app = app
 	ctx.reset(w, r)
 	return ctx
 }
@@ -64,18 +250,20 @@ func (app *App) putCtx(ctx *Context) {
 	app.contextPool.Put(ctx)
 }
 
+//Run serves HTTP on the addr, and shuts down gracefully on SIGINT or SIGTERM
 func (app *App) Run(addr string) (err error) {
-	guard.run()
-	logger.Printf("[Goil] Listening and serving HTTP on %s\n", addr)
-	err = http.ListenAndServe(addr, app)
-	return
+	return app.Server(ServerOptions{
+		Addr: addr,
+	})
 }
 
+//RunTLS serves HTTPS on the addr, and shuts down gracefully on SIGINT or SIGTERM
 func (app *App) RunTLS(addr string, certFile, keyFile string) (err error) {
-	guard.run()
-	logger.Printf("[Goil] Listening and serving HTTPS on %s\n", addr)
-	err = http.ListenAndServeTLS(addr, certFile, keyFile, app)
-	return
+	return app.Server(ServerOptions{
+		Addr:     addr,
+		CertFile: certFile,
+		KeyFile:  keyFile,
+	})
 }
 
 const banner = `` +
@@ -87,17 +275,17 @@ const banner = `` +
 	` \_________/\_______/\_/\____/  by can ` + "\n" +
 	`                                       `
 
-func echoBanner() {
+func echoBanner(l logger.ILogger) {
 	var bannerColor, resetColor string
-	if logger.IsTTY() {
+	if l.IsTTY() {
 		bannerColor = redBkg
 		resetColor = resetClr
 	}
-	logger.Printf("%s%s%s", bannerColor, banner, resetColor)
+	l.Printf("%s%s%s", bannerColor, banner, resetColor)
 }
 
-func Default() *App {
-	app := New()
+func Default(opts ...Option) *App {
+	app := New(opts...)
 	app.router.Use(PrintRequestInfo(), Recover())
 	return app
 }
@@ -146,6 +334,28 @@ func (a *App) Static(path string, filepath string) IRouter {
 func (a *App) StaticFS(path string, fs http.FileSystem) IRouter {
 	return a.router.StaticFS(path, fs)
 }
+func (a *App) Mount(path string, handler http.Handler) IRouter {
+	return a.router.Mount(path, handler)
+}
+
+//AddRoute registers the route of the default router while the app is serving, the middlewares
+//of the app are executed before the handlers. unlike ADD, it returns an error instead of panicking
+//when the route conflicts with the existing ones. the routes of App.Host can't be added at runtime
+func (a *App) AddRoute(method, path string, handlers HandlerChain, opts ...RouteOption) (*Route, error) {
+	return a.router.addRoute(method, path, combineChain(a.router.chain(), handlers...), opts...)
+}
+
+//RemoveRoute removes the route of the default router registered with the method and path pattern
+//while the app is serving, the name of the route can't be used by App.URL after that
+func (a *App) RemoveRoute(method, path string) error {
+	return a.router.removeRoute(method, path)
+}
+
+//URL generates the url of the route named name,
+//the params are filled into the ':param' and '*catchAll' of the path in order
+func (a *App) URL(name string, params ...interface{}) (string, error) {
+	return a.router.url(name, params...)
+}
 
 func (a *App) XRouter() XRouter {
 	return &GroupX{
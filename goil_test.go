@@ -0,0 +1,332 @@
+package goil
+
+import (
+	"bytes"
+	"fmt"
+	"goil/logger"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func serve(app *App, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, path, nil)
+	app.ServeHTTP(w, r)
+	return w
+}
+
+func TestRedirectTrailingSlash(t *testing.T) {
+	app := New()
+	ok := func(c *Context) { c.Text("ok") }
+	app.GET("/users", ok)
+	app.GET("/users/:id/", ok)
+	app.POST("/files/:dir/*filepath", ok)
+
+	cases := []struct {
+		method   string
+		in       string
+		code     int
+		location string
+	}{
+		{GET, "/users/", http.StatusMovedPermanently, "/users"},
+		{GET, "/users/Jim", http.StatusMovedPermanently, "/users/Jim/"},
+		{POST, "/files/img", http.StatusPermanentRedirect, "/files/img/"},
+		{GET, "/users?page=1", http.StatusOK, ""},
+	}
+	for _, cs := range cases {
+		w := serve(app, cs.method, cs.in)
+		if w.Code != cs.code {
+			t.Errorf("%s %s: code = %d; want %d", cs.method, cs.in, w.Code, cs.code)
+		}
+		if loc := w.Header().Get("Location"); loc != cs.location {
+			t.Errorf("%s %s: location = %q; want %q", cs.method, cs.in, loc, cs.location)
+		}
+	}
+
+	//the location never points to another host
+	evil := New()
+	evil.GET("/:a/:b", ok)
+	for _, path := range []string{"//evil.com/", "///evil.com/", "//evil.com/x/"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(GET, "/", nil)
+		r.URL.Path = path
+		evil.ServeHTTP(w, r)
+		if loc := w.Header().Get("Location"); strings.HasPrefix(loc, "//") {
+			t.Errorf("GET %s: location = %q", path, loc)
+		}
+	}
+
+	app.RedirectTrailingSlash = false
+	if w := serve(app, GET, "/users/"); w.Code != http.StatusNotFound {
+		t.Errorf("GET /users/: code = %d; want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestRedirectFixedPath(t *testing.T) {
+	app := New()
+	ok := func(c *Context) { c.Text("ok") }
+	app.GET("/users/:id", ok)
+	app.PUT("/static/*filepath", ok)
+
+	cases := []struct {
+		method   string
+		in       string
+		code     int
+		location string
+	}{
+		{GET, "/USERS/Jim", http.StatusMovedPermanently, "/users/Jim"},
+		{GET, "/../users//Jim", http.StatusMovedPermanently, "/users/Jim"},
+		{GET, "/Users/Jim/?q=1", http.StatusMovedPermanently, "/users/Jim?q=1"},
+		{PUT, "/Static/Css/App.css", http.StatusPermanentRedirect, "/static/Css/App.css"},
+		{GET, "/posts", http.StatusNotFound, ""},
+	}
+	for _, cs := range cases {
+		w := serve(app, cs.method, cs.in)
+		if w.Code != cs.code {
+			t.Errorf("%s %s: code = %d; want %d", cs.method, cs.in, w.Code, cs.code)
+		}
+		if loc := w.Header().Get("Location"); loc != cs.location {
+			t.Errorf("%s %s: location = %q; want %q", cs.method, cs.in, loc, cs.location)
+		}
+	}
+
+	app.RedirectFixedPath = false
+	if w := serve(app, GET, "/USERS/Jim"); w.Code != http.StatusNotFound {
+		t.Errorf("GET /USERS/Jim: code = %d; want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	app := New()
+	ok := func(c *Context) { c.Text("ok") }
+	app.GET("/users/:id", ok)
+	app.PUT("/users/:id", ok)
+	app.DELETE("/users/:id", ok)
+	app.POST("/posts", ok)
+
+	w := serve(app, POST, "/users/Jim")
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /users/Jim: code = %d; want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+	if allow := w.Header().Get(ALLOW); allow != "DELETE, GET, OPTIONS, PUT" {
+		t.Errorf("POST /users/Jim: allow = %q", allow)
+	}
+
+	//unsupported method
+	w = serve(app, "PROPFIND", "/posts")
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PROPFIND /posts: code = %d; want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+	if allow := w.Header().Get(ALLOW); allow != "OPTIONS, POST" {
+		t.Errorf("PROPFIND /posts: allow = %q", allow)
+	}
+
+	if w = serve(app, POST, "/nope"); w.Code != http.StatusNotFound {
+		t.Errorf("POST /nope: code = %d; want %d", w.Code, http.StatusNotFound)
+	}
+
+	//the path of CONNECT is empty
+	if w = serve(app, CONNECT, "example.com:443"); w.Code != http.StatusNotFound {
+		t.Errorf("CONNECT example.com:443: code = %d; want %d", w.Code, http.StatusNotFound)
+	}
+
+	app.HandleMethodNotAllowed = false
+	if w = serve(app, POST, "/users/Jim"); w.Code != http.StatusNotFound {
+		t.Errorf("POST /users/Jim: code = %d; want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestAutoOptions(t *testing.T) {
+	app := New()
+	ok := func(c *Context) { c.Text("ok") }
+	app.GET("/users/:id", ok)
+	app.PATCH("/users/:id", ok)
+	app.POST("/posts", ok)
+	app.OPTIONS("/posts", func(c *Context) { c.Status(http.StatusTeapot) })
+
+	w := serve(app, OPTIONS, "/users/Jim")
+	if w.Code != http.StatusNoContent {
+		t.Errorf("OPTIONS /users/Jim: code = %d; want %d", w.Code, http.StatusNoContent)
+	}
+	if allow := w.Header().Get(ALLOW); allow != "GET, OPTIONS, PATCH" {
+		t.Errorf("OPTIONS /users/Jim: allow = %q", allow)
+	}
+
+	w = serve(app, OPTIONS, "*")
+	if allow := w.Header().Get(ALLOW); allow != "GET, OPTIONS, PATCH, POST" {
+		t.Errorf("OPTIONS *: allow = %q", allow)
+	}
+
+	//the registered handler wins
+	if w = serve(app, OPTIONS, "/posts"); w.Code != http.StatusTeapot {
+		t.Errorf("OPTIONS /posts: code = %d; want %d", w.Code, http.StatusTeapot)
+	}
+
+	app.HandleOPTIONS = false
+	if w = serve(app, OPTIONS, "/users/Jim"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("OPTIONS /users/Jim: code = %d; want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestCustomUnmatchedHandlers(t *testing.T) {
+	app := New()
+	app.GET("/users", func(c *Context) { c.Text("ok") })
+	app.SetNotFoundHandler(func(c *Context) {
+		c.Status(http.StatusNotFound)
+		c.Text("not found")
+	})
+	app.SetNotMethodHandler(func(c *Context) {
+		c.Status(http.StatusMethodNotAllowed)
+		c.Text("not allowed")
+	})
+
+	if w := serve(app, GET, "/nope"); w.Code != http.StatusNotFound || w.Body.String() != "not found" {
+		t.Errorf("GET /nope: %d %q", w.Code, w.Body.String())
+	}
+	if w := serve(app, POST, "/users"); w.Code != http.StatusMethodNotAllowed || w.Body.String() != "not allowed" {
+		t.Errorf("POST /users: %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestAddRemoveRoute(t *testing.T) {
+	app := New()
+	app.GET("/users", func(c *Context) { c.Text("users") })
+	app.GET("/posts", func(c *Context) {}).Name("post.list")
+
+	if _, err := app.AddRoute(GET, "/users", HandlerChain{func(c *Context) {}}); err == nil {
+		t.Errorf("AddRoute should fail when the route conflicts")
+	}
+	route, err := app.AddRoute(GET, "/plugins/:name", HandlerChain{func(c *Context) {
+		c.Text(c.Param("name"))
+	}}, Summary("plugin"))
+	if err != nil {
+		t.Fatalf("AddRoute: %s", err)
+	}
+	if summary, _ := route.Get(META_SUMMARY); summary != "plugin" {
+		t.Errorf("the options aren't applied to the route")
+	}
+	if w := serve(app, GET, "/plugins/auth"); w.Code != http.StatusOK || w.Body.String() != "auth" {
+		t.Errorf("GET /plugins/auth = %d %q", w.Code, w.Body.String())
+	}
+	if len(app.Routes()) != 3 {
+		t.Errorf("Routes() = %d routes; want 3", len(app.Routes()))
+	}
+
+	if err := app.RemoveRoute(GET, "/plugins/:name"); err != nil {
+		t.Fatalf("RemoveRoute: %s", err)
+	}
+	if err := app.RemoveRoute(GET, "/plugins/:name"); err == nil {
+		t.Errorf("RemoveRoute should fail when the route doesn't exist")
+	}
+	if w := serve(app, GET, "/plugins/auth"); w.Code != http.StatusNotFound {
+		t.Errorf("GET /plugins/auth = %d; want %d", w.Code, http.StatusNotFound)
+	}
+	if w := serve(app, GET, "/users"); w.Body.String() != "users" {
+		t.Errorf("GET /users = %q", w.Body.String())
+	}
+	if err := app.RemoveRoute(GET, "/posts"); err != nil {
+		t.Fatalf("RemoveRoute: %s", err)
+	}
+	if _, err := app.URL("post.list"); err == nil {
+		t.Errorf("URL of the removed route should fail")
+	}
+	if len(app.Routes()) != 1 {
+		t.Errorf("Routes() = %d routes; want 1", len(app.Routes()))
+	}
+
+	//register and serve concurrently
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			app.AddRoute(GET, "/hot", HandlerChain{func(c *Context) { c.Text("hot") }})
+			app.RemoveRoute(GET, "/hot")
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if w := serve(app, GET, "/users"); w.Body.String() != "users" {
+			t.Fatalf("GET /users = %q while registering", w.Body.String())
+		}
+		serve(app, GET, "/hot")
+	}
+	<-done
+}
+
+func TestAppConfig(t *testing.T) {
+	type Params struct {
+		N int `path:"n" validator:"even"`
+	}
+	var out bytes.Buffer
+	even := func(f ValidatedField) bool {
+		return f.Value.Int()%2 == 0
+	}
+	admin := New(
+		WithMode(PRD_MODE),
+		WithLogger(logger.New(&out, "", 0, logger.DebugLevel, 2)),
+		WithValidator("even", even),
+		WithConvert("hex", func(value string, dTyp reflect.Type) (interface{}, error) {
+			return strconv.ParseUint(value, 16, 64)
+		}),
+	)
+	public := New(WithMode(UAT_MODE))
+	if admin.Mode() != PRD || public.Mode() != UAT {
+		t.Errorf("Mode() = %s, %s; want %s, %s", admin.Mode(), public.Mode(), PRD, UAT)
+	}
+
+	bindN := func(c *Context) {
+		p := Params{}
+		if err := c.Bind(&p); err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		c.Text(strconv.Itoa(p.N))
+	}
+	admin.GET("/n/:n", bindN)
+	admin.GET("/colors/:rgb<hex>", func(c *Context) {
+		c.Text(fmt.Sprint(c.TypedParam("rgb")))
+	})
+	public.GET("/n/:n", bindN)
+
+	if w := serve(admin, GET, "/n/3"); w.Code != http.StatusBadRequest {
+		t.Errorf("admin GET /n/3 = %d; want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := serve(admin, GET, "/colors/ff"); w.Body.String() != "255" {
+		t.Errorf("admin GET /colors/ff = %q", w.Body.String())
+	}
+	if !strings.Contains(out.String(), "running in PRD mode") {
+		t.Errorf("the logger of the app isn't used: %q", out.String())
+	}
+	//the validator and the convert aren't registered for the public app
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("the public app shouldn't support the constraint hex")
+			}
+		}()
+		public.GET("/colors/:rgb<hex>", func(c *Context) {})
+	}()
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("the public app shouldn't support the validator even")
+			}
+		}()
+		serve(public, GET, "/n/3")
+	}()
+
+	//the guard of the apps are independent
+	admin.guard.run()
+	defer admin.guard.reset()
+	public.GET("/ping", func(c *Context) { c.Text("pong") })
+	admin.GET("/ping", func(c *Context) { c.Text("pong") })
+	if w := serve(public, GET, "/ping"); w.Body.String() != "pong" {
+		t.Errorf("the public app should register after the admin app is running")
+	}
+	if w := serve(admin, GET, "/ping"); w.Code != http.StatusNotFound {
+		t.Errorf("the admin app shouldn't register while running")
+	}
+}
@@ -2,7 +2,6 @@ package goil
 
 import (
 	"fmt"
-	"goil/logger"
 	. "goil/reflect"
 	"mime"
 	"net/http"
@@ -29,6 +28,9 @@ type XRouter interface {
 	StaticFS(path string, fs http.FileSystem) XRouter
 	SetRenderHandler(handler RenderHandler)
 	SetErrorHandler(handler ErrorHandler)
+	Name(name string) XRouter
+	Mount(path string, handler http.Handler) XRouter
+	With(opts ...RouteOption) XRouter
 }
 
 var _ XRouter = new(GroupX)
@@ -141,7 +143,7 @@ func (g *GroupX) Group(path string, handlers ...HandlerFunc) XRouter {
 
 	return &GroupX{
 		group: &group{
-			middlewares: combineChain(g.group.middlewares, handlers...),
+			middlewares: combineChain(g.group.chain(), handlers...),
 			base:        joinPath(g.group.base, path),
 			router:      g.group.router,
 		},
@@ -164,9 +166,10 @@ func (g *GroupX) ADD(method, path string, handler ...interface{}) XRouter {
 			assert1(ok, fmt.Errorf("the type of middleware must be func (*Context)"))
 		}
 	}
-	ml := len(g.group.middlewares)
+	middlewares := g.group.chain()
+	ml := len(middlewares)
 	chain := make(HandlerChain, ml, ml+l)
-	copy(chain, g.group.middlewares)
+	copy(chain, middlewares)
 	for i, h := range handler {
 		if i == l-1 {
 			chain = append(chain, g.Wrapper(h))
@@ -176,11 +179,14 @@ func (g *GroupX) ADD(method, path string, handler ...interface{}) XRouter {
 	}
 	absolutePath := joinPath(g.group.base, path)
 
-	g.group.router.add(method, absolutePath, chain)
-	if RunMode() == DBG {
-		handlerNum := len(chain)
-		handlerName := funcName(handler[l-1])
-		printRouteInfo(method, absolutePath, handlerName, handlerNum)
+	handlerNum := len(chain)
+	handlerName := funcName(handler[l-1])
+	g.group.last = g.group.last[:0:0]
+	if route := g.group.router.add(method, absolutePath, chain, handlerName); route != nil {
+		g.group.last = append(g.group.last, route)
+	}
+	if g.group.router.conf.mode() == DBG {
+		g.group.router.printRouteInfo(method, absolutePath, handlerName, handlerNum)
 	}
 	return g
 }
@@ -210,9 +216,12 @@ func (g *GroupX) TRACE(path string, handlers ...interface{}) XRouter {
 	return g.ADD(TRACE, path, handlers...)
 }
 func (g *GroupX) ANY(path string, handlers ...interface{}) XRouter {
+	last := make([]*Route, 0, len(methods))
 	for methods := range methods {
 		g.ADD(methods, path, handlers...)
+		last = append(last, g.group.last...)
 	}
+	g.group.last = last
 	return g
 }
 
@@ -226,8 +235,25 @@ func (g *GroupX) StaticFS(path string, fs http.FileSystem) XRouter {
 	return g
 }
 
+func (g *GroupX) Mount(path string, handler http.Handler) XRouter {
+	g.group.Mount(path, handler)
+	return g
+}
+
+func (g *GroupX) With(opts ...RouteOption) XRouter {
+	g.group.With(opts...)
+	return g
+}
+
+func (g *GroupX) Name(name string) XRouter {
+	g.group.Name(name)
+	return g
+}
+
+//DefErrHandler logs the error and responds 500 with the error text,
+//the errors of XRouter aren't handled by the App.ErrorHandler unless the ErrorHandler records them by Context.Error
 func DefErrHandler(c *Context, err error) {
-	logger.Errorf("when handler reqest:%s", err)
+	c.Logger().Errorf("when handler reqest:%s", err)
 	c.Status(http.StatusInternalServerError)
 	c.Text(err.Error())
 }
@@ -1,11 +1,13 @@
 package goil
 
 import (
+	"html/template"
+	"net/http"
 	"testing"
 )
 
 func TestADD(t *testing.T) {
-	route := newRouter()
+	route := newRouter(nil, new(Guard))
 	route.Use(func(c *Context) {
 		t.Errorf("router")
 		c.Next()
@@ -25,9 +27,108 @@ func TestADD(t *testing.T) {
 		t.Errorf("test1")
 	})
 
-	chain, _, _ := route.route("GET", "/test")
+	chain, _, _, _ := route.route("GET", "/test")
 	c := &Context{
 		chain: chain,
 	}
 	c.Next()
 }
+
+func TestNamedRoutes(t *testing.T) {
+	app := New()
+	h := func(c *Context) {}
+	app.GET("/hello/:who", h).Name("hello")
+	v1 := app.Group("/v1")
+	v1.GET("/users/:id", h).Name("user.show").POST("/users", h).Name("user.create")
+	app.XRouter().Group("/x").GET("/greet/:who", func() string { return "" }).Name("greet")
+	app.Static("/assets", ".").Name("assets")
+
+	cases := []struct {
+		name   string
+		params []interface{}
+		out    string
+	}{
+		{"hello", []interface{}{"Jim"}, "/hello/Jim"},
+		{"user.show", []interface{}{10}, "/v1/users/10"},
+		{"user.create", nil, "/v1/users"},
+		{"greet", []interface{}{"Tom"}, "/x/greet/Tom"},
+		{"assets", []interface{}{"css/app.css"}, "/assets/css/app.css"},
+	}
+	for _, cs := range cases {
+		out, err := app.URL(cs.name, cs.params...)
+		if err != nil || out != cs.out {
+			t.Errorf("URL(%q, %v) = %q, %v; want %q", cs.name, cs.params, out, err, cs.out)
+		}
+	}
+	if _, err := app.URL("nope"); err == nil {
+		t.Error("URL of the unknown name should fail")
+	}
+	//both GET and HEAD of Static are named
+	for _, route := range app.Routes() {
+		if route.Path == "/assets/*filepath" && route.Name != "assets" {
+			t.Errorf("%s %s isn't named", route.Method, route.Path)
+		}
+	}
+
+	//the templates shared by the apps generate the urls of the app rendering them
+	html := NewHtmlTemp()
+	template.Must(html.T.New("link").Parse(`<a href="{{url "user.show" .}}">`))
+	app.conf.HtmlRender = html
+	other := New(WithHtmlRender(html))
+	other.GET("/members/:id", h).Name("user.show")
+	link := func(c *Context) { c.Html("link", 7) }
+	app.GET("/link", link)
+	other.GET("/link", link)
+	if body := serve(app, GET, "/link").Body.String(); body != `<a href="/v1/users/7">` {
+		t.Errorf("template url = %q", body)
+	}
+	if body := serve(other, GET, "/link").Body.String(); body != `<a href="/members/7">` {
+		t.Errorf("template url of the other app = %q", body)
+	}
+}
+
+func TestMount(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/vars", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("vars:" + r.URL.Path))
+	})
+
+	sub := New()
+	sub.GET("/users/:id", func(c *Context) {
+		c.Text("user:" + c.Param("id") + ":" + c.Request.URL.Path)
+	})
+	sub.GET("/", func(c *Context) { c.Text("index") })
+
+	app := New()
+	var trace []string
+	admin := app.Group("/admin", func(c *Context) {
+		trace = append(trace, c.Request.URL.Path)
+		c.Next()
+	})
+	admin.Mount("/sub", sub)
+	app.Mount("/debug/", mux)
+
+	cases := []struct {
+		path     string
+		code     int
+		body     string
+		location string
+	}{
+		{"/debug/vars", http.StatusOK, "vars:/vars", ""},
+		{"/admin/sub/users/1", http.StatusOK, "user:1:/users/1", ""},
+		{"/admin/sub", http.StatusOK, "index", ""},
+		{"/admin/sub/", http.StatusOK, "index", ""},
+		{"/admin/sub/users/1/", http.StatusMovedPermanently, "", "/admin/sub/users/1"},
+		{"/admin/sub/USERS/1", http.StatusMovedPermanently, "", "/admin/sub/users/1"},
+		{"/admin/sub/nope", http.StatusNotFound, "", ""},
+	}
+	for _, cs := range cases {
+		w := serve(app, GET, cs.path)
+		if w.Code != cs.code || (cs.body != "" && w.Body.String() != cs.body) || w.Header().Get("Location") != cs.location {
+			t.Errorf("GET %s: %d %q %q", cs.path, w.Code, w.Body.String(), w.Header().Get("Location"))
+		}
+	}
+	if len(trace) != 6 || trace[0] != "/admin/sub/users/1" {
+		t.Errorf("the group middlewares should run before the mounted handler: %v", trace)
+	}
+}
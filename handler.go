@@ -4,7 +4,10 @@
 package goil
 
 import (
+	"context"
 	"net/http"
+	"net/url"
+	"strings"
 )
 
 type HandlerFunc = func(*Context)
@@ -28,5 +31,45 @@ func NotFoundHandler(c *Context) {
 }
 
 func NotMethodHandler(c *Context) {
-	c.Status(http.StatusNotFound)
+	c.Status(http.StatusMethodNotAllowed)
+}
+
+//OptionsHandler answers the OPTIONS request automatically,
+//the Allow header has been set by the app
+func OptionsHandler(c *Context) {
+	c.Status(http.StatusNoContent)
+}
+
+type mountPrefixKey struct{}
+
+//mountHandler strips the prefix from the request path and calls the handler,
+//the prefix is saved to the request context, so the mounted *App can redirect correctly
+func mountHandler(prefix string, handler http.Handler) HandlerFunc {
+	return func(c *Context) {
+		r := c.Request
+		p := strings.TrimPrefix(r.URL.Path, prefix)
+		if p == "" || p[0] != '/' {
+			p = "/" + p
+		}
+		rp := ""
+		if r.URL.RawPath != "" {
+			rp = strings.TrimPrefix(r.URL.RawPath, prefix)
+			if rp == "" || rp[0] != '/' {
+				rp = "/" + rp
+			}
+		}
+		outer, _ := r.Context().Value(mountPrefixKey{}).(string)
+		r2 := r.WithContext(context.WithValue(r.Context(), mountPrefixKey{}, outer+prefix))
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = p
+		r2.URL.RawPath = rp
+		handler.ServeHTTP(c.Response, r2)
+	}
+}
+
+//mountPrefix returns the prefix stripped by the mount handlers
+func mountPrefix(r *http.Request) string {
+	prefix, _ := r.Context().Value(mountPrefixKey{}).(string)
+	return prefix
 }
@@ -0,0 +1,161 @@
+package goil
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+//hostRouter routes the requests whose host matches the pattern
+type hostRouter struct {
+	*router
+	//the labels of the host pattern, '{name}' captures a label as the param
+	labels []string
+	//match the port too if the pattern has a port
+	port string
+}
+
+func newHostRouter(pattern string, parent *router) *hostRouter {
+	host, port := pattern, ""
+	if h, p, err := net.SplitHostPort(pattern); err == nil {
+		host, port = h, p
+	}
+	assert1(host != "", "the host pattern can't be empty")
+	labels := strings.Split(strings.ToLower(host), ".")
+	for _, label := range labels {
+		assert1(label != "", fmt.Sprintf("invalid host pattern: %s", pattern))
+		if label[0] == '{' {
+			assert1(len(label) > 2 && label[len(label)-1] == '}', fmt.Sprintf("invalid host pattern: %s", pattern))
+		}
+	}
+	rt := newRouter(parent.conf, parent.guard)
+	rt.host = pattern
+	//share the route names with the default router, so App.URL works for all hosts
+	rt.names = parent.names
+	//the middlewares used by App.Use run for all hosts
+	rt.group.parent = parent.group
+	return &hostRouter{
+		router: rt,
+		labels: labels,
+		port:   port,
+	}
+}
+
+//match checks the host, and returns the captured params if matched
+func (h *hostRouter) match(host string) (Params, bool) {
+	name, port := host, ""
+	if n, p, err := net.SplitHostPort(host); err == nil {
+		name, port = n, p
+	}
+	if h.port != "" && h.port != port {
+		return nil, false
+	}
+	var params Params
+	for _, label := range h.labels {
+		if name == "" {
+			return nil, false
+		}
+		end := strings.IndexByte(name, '.')
+		if end < 0 {
+			end = len(name)
+		}
+		value := name[:end]
+		if label[0] == '{' {
+			if params == nil {
+				params = make(Params, 0, len(h.labels))
+			}
+			params.set(label[1:len(label)-1], value)
+		} else if !strings.EqualFold(label, value) {
+			return nil, false
+		}
+		name = name[end:]
+		if name != "" {
+			name = name[1:]
+			if name == "" {
+				//trailing dot
+				return nil, false
+			}
+		}
+	}
+	if name != "" {
+		return nil, false
+	}
+	return params, true
+}
+
+//matchHost returns the router for the host, the more specific host patterns are checked firstly,
+//and the default router is returned if no host matches
+func (app *App) matchHost(host string) (*router, Params) {
+	for _, h := range app.hosts {
+		if params, ok := h.match(host); ok {
+			return h.router, params
+		}
+	}
+	return app.router, nil
+}
+
+func (app *App) hostRouter(pattern string) *hostRouter {
+	for _, h := range app.hosts {
+		if h.host == pattern {
+			return h
+		}
+	}
+	h := newHostRouter(pattern, app.router)
+	app.guard.execSafely(func() {
+		app.hosts = append(app.hosts, h)
+		//the more specific pattern is checked firstly
+		sort.SliceStable(app.hosts, func(i, j int) bool {
+			return app.hosts[i].specificity() > app.hosts[j].specificity()
+		})
+	})
+	return h
+}
+
+//the pattern with port and less captures is more specific
+func (h *hostRouter) specificity() int {
+	n := 0
+	for _, label := range h.labels {
+		if label[0] != '{' {
+			n++
+		}
+	}
+	if h.port != "" {
+		n += len(h.labels) + 1
+	}
+	return n
+}
+
+//Host returns a router with its own route table for the requests whose host matches the pattern,
+//'{name}' in the pattern captures a label of the host, which can be read by Context.Param:
+//	api := app.Host("{tenant}.example.com")
+//	api.GET("/users", func(c *goil.Context) {
+//		tenant := c.Param("tenant")
+//	})
+//the middlewares added by App.Use run before the ones of the host router,
+//and the requests of the unmatched hosts are routed by the default router
+func (app *App) Host(pattern string) IRouter {
+	return app.hostRouter(pattern).group
+}
+
+//HostX is the XRouter version of Host
+func (app *App) HostX(pattern string) XRouter {
+	return &GroupX{
+		group:         app.hostRouter(pattern).group,
+		ErrorHandler:  DefErrHandler,
+		RenderHandler: DefRenderHandler,
+	}
+}
+
+//joinParams puts the host params before the path params
+func joinParams(hostParams, params Params) Params {
+	if len(hostParams) == 0 {
+		return params
+	}
+	if len(params) == 0 {
+		return hostParams
+	}
+	joined := make(Params, 0, len(hostParams)+len(params))
+	joined = append(joined, hostParams...)
+	return append(joined, params...)
+}
@@ -0,0 +1,69 @@
+package goil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHostRouting(t *testing.T) {
+	app := New()
+	app.GET("/users", func(c *Context) { c.Text("default") })
+	app.Host("{tenant}.example.com").GET("/users", func(c *Context) {
+		c.Text("tenant:" + c.Param("tenant"))
+	})
+	app.Host("admin.example.com:8080").GET("/users/:id", func(c *Context) {
+		c.Text("admin:" + c.Param("id"))
+	})
+	app.HostX("api.{region}.example.com").GET("/users/:id", func(c *Context) string {
+		return c.Param("region") + ":" + c.Param("id")
+	})
+
+	cases := []struct {
+		host string
+		path string
+		code int
+		body string
+	}{
+		{"acme.example.com", "/users", http.StatusOK, "tenant:acme"},
+		{"ACME.Example.com:80", "/users", http.StatusOK, "tenant:ACME"},
+		{"admin.example.com:8080", "/users/1", http.StatusOK, "admin:1"},
+		{"api.eu.example.com", "/users/2", http.StatusOK, "eu:2"},
+		{"a.b.example.com", "/users", http.StatusOK, "default"},
+		{"example.com", "/users", http.StatusOK, "default"},
+		{"localhost:8080", "/users", http.StatusOK, "default"},
+		{"api.eu.example.com", "/users", http.StatusNotFound, ""},
+	}
+	for _, cs := range cases {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(GET, cs.path, nil)
+		r.Host = cs.host
+		app.ServeHTTP(w, r)
+		if w.Code != cs.code || w.Body.String() != cs.body {
+			t.Errorf("%s%s: %d %q; want %d %q", cs.host, cs.path, w.Code, w.Body.String(), cs.code, cs.body)
+		}
+	}
+
+	if routes := app.Routes(); len(routes) != 4 || routes[0].Host != "" || routes[1].Host != "admin.example.com:8080" {
+		t.Errorf("Routes() = %+v", routes)
+	}
+}
+
+func TestHostMiddlewares(t *testing.T) {
+	app := New()
+	admin := app.Host("admin.example.com")
+	//the middlewares of the app are used even if they are added after Host
+	app.Use(func(c *Context) { c.SetHeader("X-App", "1") })
+	admin.Use(func(c *Context) { c.SetHeader("X-Host", "1") })
+	admin.GET("/users", func(c *Context) { c.Text("admin") })
+
+	for _, path := range []string{"/users", "/posts"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(GET, path, nil)
+		r.Host = "admin.example.com"
+		app.ServeHTTP(w, r)
+		if w.Header().Get("X-App") != "1" || w.Header().Get("X-Host") != "1" {
+			t.Errorf("%s: the middlewares aren't run, header = %v", path, w.Header())
+		}
+	}
+}
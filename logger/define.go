@@ -52,6 +52,11 @@ func SetLogger(l ILogger) {
 	}
 }
 
+//Default returns the logger set by SetLogger
+func Default() ILogger {
+	return defLogger
+}
+
 func Printf(format string, msg ...interface{}) {
 	defLogger.Printf(format, msg...)
 }
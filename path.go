@@ -3,7 +3,12 @@
  */
 package goil
 
-import "path"
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
 
 /**
 预处理url：
@@ -146,3 +151,52 @@ func joinPath(basePath, relativePath string) string {
 	return path
 
 }
+
+/**
+根据路由模式生成URL：
+	1. 按顺序使用params填充 `:name` 和 `*name`
+	2. `:name` 的值会被转义
+	3. `*name` 的值按 '/' 分段转义
+	4. params的个数必须与路由参数的个数一致
+
+case：
+	pattern				params			output
+	/hello/:who			Jim				/hello/Jim
+	/static/*filepath	css/a b.css		/static/css/a%20b.css
+**/
+func fillPath(pattern string, params ...interface{}) (string, error) {
+	buf := make([]byte, 0, len(pattern))
+	i := 0
+	for idx, l := 0, len(pattern); idx < l; idx++ {
+		c := pattern[idx]
+		if c != ':' && c != '*' {
+			buf = append(buf, c)
+			continue
+		}
+		if i >= len(params) {
+			return "", fmt.Errorf("missing params for '%s'", pattern)
+		}
+		//skip the param name
+		for idx+1 < l && pattern[idx+1] != '/' {
+			idx++
+		}
+		value := fmt.Sprint(params[i])
+		i++
+		if c == ':' {
+			buf = append(buf, url.PathEscape(value)...)
+			continue
+		}
+		//the catch all param must be the last one
+		segs := strings.Split(strings.TrimPrefix(value, "/"), "/")
+		for j, seg := range segs {
+			if j > 0 {
+				buf = append(buf, '/')
+			}
+			buf = append(buf, url.PathEscape(seg)...)
+		}
+	}
+	if i != len(params) {
+		return "", fmt.Errorf("too many params for '%s'", pattern)
+	}
+	return string(buf), nil
+}
@@ -18,3 +18,28 @@ func TestJoinPath(t *testing.T) {
 		t.Error(url)
 	}
 }
+
+func TestFillPath(t *testing.T) {
+	cases := []struct {
+		pattern string
+		params  []interface{}
+		out     string
+		err     bool
+	}{
+		{"/hello/:who", []interface{}{"Jim"}, "/hello/Jim", false},
+		{"/users/:id/posts/:pid", []interface{}{1, 20}, "/users/1/posts/20", false},
+		{"/users/:id", []interface{}{"a b/c"}, "/users/a%20b%2Fc", false},
+		{"/static/*filepath", []interface{}{"css/a b.css"}, "/static/css/a%20b.css", false},
+		{"/static/*filepath", []interface{}{"/js/app.js"}, "/static/js/app.js", false},
+		{"/users", nil, "/users", false},
+		{"/v:version<uint>/users/:id<int>", []interface{}{2, 10}, "/v2/users/10", false},
+		{"/users/:id", nil, "", true},
+		{"/users", []interface{}{1}, "", true},
+	}
+	for _, cs := range cases {
+		out, err := fillPath(cs.pattern, cs.params...)
+		if (err != nil) != cs.err || out != cs.out {
+			t.Errorf("fillPath(%q, %v) = %q, %v", cs.pattern, cs.params, out, err)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package goil
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	FORWARDED          = "Forwarded"
+	X_FORWARDED_FOR    = "X-Forwarded-For"
+	X_FORWARDED_PROTO  = "X-Forwarded-Proto"
+	X_FORWARDED_HOST   = "X-Forwarded-Host"
+	X_REAL_IP          = "X-Real-Ip"
+	X_APPENGINE_REMOTE = "X-Appengine-Remote-Addr"
+)
+
+//SetTrustedProxies sets the proxies whose forwarding headers are trusted by Context.ClientIP,
+//Context.Scheme and Context.Host, the proxies are the IPs or the CIDRs:
+//	app.SetTrustedProxies("127.0.0.1", "10.0.0.0/8", "fd00::/8")
+//no proxy is trusted by default, so the forwarding headers are ignored.
+//only the header set by WithForwardedHeader is read, X-Forwarded-For by default
+func (app *App) SetTrustedProxies(proxies ...string) error {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return fmt.Errorf("invalid proxy %q", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return fmt.Errorf("invalid proxy %q: %s", proxy, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	app.conf.TrustedProxies = nets
+	return nil
+}
+
+//forwardedHop is the client forwarded by the proxies
+type forwardedHop struct {
+	ip    string
+	proto string
+	host  string
+}
+
+//forwarded returns the client forwarded by the trusted proxies, ok is false if the request
+//isn't from the trusted proxy. the hops of the forwarded header of the app are checked from right to left,
+//and the first untrusted one is the client, the leftmost is the client if all hops are trusted.
+//the single value headers such as X-Real-Ip are the client directly
+func (c *Context) forwarded() (hop forwardedHop, ok bool) {
+	nets := c.config().trustedProxies()
+	if len(nets) == 0 {
+		return hop, false
+	}
+	remote, _, err := net.SplitHostPort(strings.TrimSpace(c.Request.RemoteAddr))
+	if err != nil || !trustedIP(nets, net.ParseIP(remote)) {
+		return hop, false
+	}
+
+	header := c.Request.Header
+	var hops []forwardedHop
+	switch name := c.config().forwardedHeader(); name {
+	case FORWARDED:
+		hops = parseForwarded(header[FORWARDED])
+	case X_FORWARDED_FOR:
+		hops = parseXForwarded(header)
+	default:
+		hop.ip = forwardedNode(strings.TrimSpace(header.Get(name)))
+		if net.ParseIP(hop.ip) == nil {
+			return forwardedHop{}, false
+		}
+		hop.proto = alignedValue(headerList(header[X_FORWARDED_PROTO]), 0)
+		hop.host = alignedValue(headerList(header[X_FORWARDED_HOST]), 0)
+		return hop, true
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(hops[i].ip)
+		if ip == nil {
+			//unknown or obfuscated, it can't be trusted
+			return hop, false
+		}
+		if i == 0 || !trustedIP(nets, ip) {
+			return hops[i], true
+		}
+	}
+	return hop, false
+}
+
+func trustedIP(nets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+//parseForwarded parses the RFC 7239 Forwarded headers:
+//	Forwarded: for=192.0.2.60;proto=https;host=example.com, for="[2001:db8:cafe::17]:4711"
+func parseForwarded(values []string) (hops []forwardedHop) {
+	for _, value := range values {
+		for _, element := range splitQuoted(value, ',') {
+			hop := forwardedHop{}
+			for _, pair := range splitQuoted(element, ';') {
+				eq := strings.IndexByte(pair, '=')
+				if eq < 0 {
+					continue
+				}
+				v := strings.TrimSpace(pair[eq+1:])
+				if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+					v = v[1 : len(v)-1]
+				}
+				switch strings.ToLower(strings.TrimSpace(pair[:eq])) {
+				case "for":
+					hop.ip = forwardedNode(v)
+				case "proto":
+					hop.proto = v
+				case "host":
+					hop.host = v
+				}
+			}
+			hops = append(hops, hop)
+		}
+	}
+	return
+}
+
+//forwardedNode returns the IP of the node with the optional port, such as "[2001:db8::1]:4711"
+func forwardedNode(node string) string {
+	if strings.HasPrefix(node, "[") {
+		if end := strings.IndexByte(node, ']'); end > 0 {
+			return node[1:end]
+		}
+		return node
+	}
+	if strings.Count(node, ":") == 1 {
+		return node[:strings.IndexByte(node, ':')]
+	}
+	return node
+}
+
+//splitQuoted splits the s by the sep out of the quoted strings
+func splitQuoted(s string, sep byte) (parts []string) {
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+//parseXForwarded parses X-Forwarded-For, and the X-Forwarded-Proto and X-Forwarded-Host
+//are aligned with it from right to left, the leftmost ones are used for the rest hops
+func parseXForwarded(header http.Header) (hops []forwardedHop) {
+	ips := headerList(header[X_FORWARDED_FOR])
+	protos := headerList(header[X_FORWARDED_PROTO])
+	hosts := headerList(header[X_FORWARDED_HOST])
+	for i, ip := range ips {
+		right := len(ips) - 1 - i
+		hops = append(hops, forwardedHop{
+			ip:    ip,
+			proto: alignedValue(protos, right),
+			host:  alignedValue(hosts, right),
+		})
+	}
+	return
+}
+
+//headerList returns the comma separated values of the headers
+func headerList(values []string) (list []string) {
+	for _, value := range values {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				list = append(list, v)
+			}
+		}
+	}
+	return
+}
+
+//alignedValue returns the value at the index from the right
+func alignedValue(values []string, right int) string {
+	if len(values) == 0 {
+		return ""
+	}
+	if right >= len(values) {
+		return values[0]
+	}
+	return values[len(values)-1-right]
+}
@@ -0,0 +1,60 @@
+package goil
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrustedProxies(t *testing.T) {
+	app := New()
+	if err := app.SetTrustedProxies("10.0.0.0/8", "bad"); err == nil {
+		t.Errorf("SetTrustedProxies should fail with the invalid proxy")
+	}
+	if err := app.SetTrustedProxies("10.0.0.0/8", "::1"); err != nil {
+		t.Fatalf("SetTrustedProxies: %s", err)
+	}
+	var ip, scheme, host string
+	app.GET("/", func(c *Context) {
+		ip, scheme, host = c.ClientIP(), c.Scheme(), c.Host()
+	})
+
+	cases := []struct {
+		//the forwarded header of the app, X-Forwarded-For if it is empty
+		forwarded string
+		remote    string
+		headers   map[string]string
+		ip        string
+		scheme    string
+		host      string
+	}{
+		//not from the trusted proxy
+		{"", "203.0.113.9:80", map[string]string{X_FORWARDED_FOR: "1.1.1.1", X_FORWARDED_PROTO: "https"}, "203.0.113.9", "http", "example.com"},
+		//the spoofed hop on the left is ignored
+		{"", "10.0.0.1:80", map[string]string{X_FORWARDED_FOR: "1.1.1.1, 203.0.113.7, 10.0.0.2", X_FORWARDED_PROTO: "https", X_FORWARDED_HOST: "api.example.com"}, "203.0.113.7", "https", "api.example.com"},
+		//all hops are trusted
+		{"", "[::1]:80", map[string]string{X_FORWARDED_FOR: "10.0.0.3, 10.0.0.2"}, "10.0.0.3", "http", "example.com"},
+		{"", "10.0.0.1:80", map[string]string{X_FORWARDED_FOR: "unknown"}, "10.0.0.1", "http", "example.com"},
+		//the headers other than the forwarded header of the app are sent by the client
+		{"", "10.0.0.1:80", map[string]string{FORWARDED: "for=1.1.1.1", X_FORWARDED_FOR: "203.0.113.9"}, "203.0.113.9", "http", "example.com"},
+		{"", "10.0.0.1:80", map[string]string{X_REAL_IP: "1.1.1.1"}, "10.0.0.1", "http", "example.com"},
+		{X_REAL_IP, "10.0.0.1:80", map[string]string{X_REAL_IP: "203.0.113.5", X_FORWARDED_PROTO: "https"}, "203.0.113.5", "https", "example.com"},
+		{X_REAL_IP, "10.0.0.1:80", map[string]string{X_FORWARDED_FOR: "1.1.1.1"}, "10.0.0.1", "http", "example.com"},
+		{FORWARDED, "10.0.0.1:80", map[string]string{
+			FORWARDED:       `for=1.1.1.1, for="[2001:db8:cafe::17]:4711";proto=https;host="shop.example.com", for=10.0.0.2:8080`,
+			X_FORWARDED_FOR: "198.51.100.1",
+		}, "2001:db8:cafe::17", "https", "shop.example.com"},
+		{FORWARDED, "10.0.0.1:80", map[string]string{X_FORWARDED_FOR: "1.1.1.1"}, "10.0.0.1", "http", "example.com"},
+	}
+	for _, cs := range cases {
+		app.conf.ForwardedHeader = cs.forwarded
+		r := httptest.NewRequest(GET, "http://example.com/", nil)
+		r.RemoteAddr = cs.remote
+		for k, v := range cs.headers {
+			r.Header.Set(k, v)
+		}
+		app.ServeHTTP(httptest.NewRecorder(), r)
+		if ip != cs.ip || scheme != cs.scheme || host != cs.host {
+			t.Errorf("%s %s %v: %s %s %s; want %s %s %s", cs.forwarded, cs.remote, cs.headers, ip, scheme, host, cs.ip, cs.scheme, cs.host)
+		}
+	}
+}
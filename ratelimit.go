@@ -0,0 +1,281 @@
+package goil
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	X_RATELIMIT_LIMIT     = "X-RateLimit-Limit"
+	X_RATELIMIT_REMAINING = "X-RateLimit-Remaining"
+	X_RATELIMIT_RESET     = "X-RateLimit-Reset"
+	RETRY_AFTER           = "Retry-After"
+)
+
+//the algorithms of RateLimit
+const (
+	//TOKEN_BUCKET allows the burst of Limit requests, and refills Limit tokens per Period
+	TOKEN_BUCKET = iota
+	//SLIDING_WINDOW allows Limit requests in any Period, which is weighted by the previous fixed window
+	SLIDING_WINDOW
+)
+
+//the route metadata key of the rate limit class set by RateClass
+const META_RATE_CLASS = "rateClass"
+
+//TooManyRequests is recorded by RateLimit when the requests are over the limit,
+//the error recorded is a copy which can be checked by errors.Is
+var TooManyRequests = NewHTTPError(http.StatusTooManyRequests, "too many requests.")
+
+//Rate allows Limit requests per Period, there is no limit if Limit isn't positive
+type Rate struct {
+	Limit  int
+	Period time.Duration
+}
+
+//RateLimitResult is the result of taking a request from the RateLimitStore
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	//how long until the limit is fully reset
+	Reset time.Duration
+	//how long until the next request is allowed if it isn't allowed
+	RetryAfter time.Duration
+}
+
+//RateLimitStore counts the requests of the keys for RateLimit
+type RateLimitStore interface {
+	TokenBucket(key string, rate Rate) (RateLimitResult, error)
+	SlidingWindow(key string, rate Rate) (RateLimitResult, error)
+}
+
+//RateLimitConfig configures the middleware created by RateLimit
+type RateLimitConfig struct {
+	//TOKEN_BUCKET or SLIDING_WINDOW
+	Algorithm int
+	//the rate of the routes without the class
+	Rate Rate
+	//the rates of the classes set by RateClass, the routes of the same class share the limit
+	Classes map[string]Rate
+	//Key returns the key limited, KeyByIP is used if it is nil
+	Key func(c *Context) string
+	//the prefix of the keys in the Store, "ratelimit:" is used if it is empty
+	Prefix string
+	//NewMemoryLimitStore is used if it is nil
+	Store RateLimitStore
+}
+
+//RateClass sets the rate limit class of the route:
+//	app.POST("/login", login).With(goil.RateClass("auth"))
+func RateClass(class string) RouteOption {
+	return Meta(META_RATE_CLASS, class)
+}
+
+//KeyByIP limits the requests by Context.ClientIP
+func KeyByIP(c *Context) string {
+	return c.ClientIP()
+}
+
+//KeyByHeader limits the requests by the header such as the api key
+func KeyByHeader(name string) func(c *Context) string {
+	return func(c *Context) string {
+		return c.Header(name)
+	}
+}
+
+//RateLimit returns the middleware limiting the requests by the key and the class of the route,
+//the X-RateLimit-* headers are set, and TooManyRequests is recorded with the Retry-After header
+//when the requests are over the limit. the requests are allowed if the store fails
+func RateLimit(conf RateLimitConfig) HandlerFunc {
+	if conf.Key == nil {
+		conf.Key = KeyByIP
+	}
+	if conf.Prefix == "" {
+		conf.Prefix = "ratelimit:"
+	}
+	if conf.Store == nil {
+		conf.Store = NewMemoryLimitStore()
+	}
+	take := conf.Store.TokenBucket
+	if conf.Algorithm == SLIDING_WINDOW {
+		take = conf.Store.SlidingWindow
+	}
+
+	return func(c *Context) {
+		rate := conf.Rate
+		class, _ := c.Route().Get(META_RATE_CLASS)
+		name, _ := class.(string)
+		if r, ok := conf.Classes[name]; ok {
+			rate = r
+		}
+		if rate.Limit <= 0 || rate.Period <= 0 {
+			return
+		}
+
+		result, err := take(conf.Prefix+name+":"+conf.Key(c), rate)
+		if err != nil {
+			c.Logger().Errorf("when limiting the rate of %s %s: %s", c.Request.Method, c.Request.URL.Path, err)
+			return
+		}
+		header := c.Response.Header()
+		header.Set(X_RATELIMIT_LIMIT, strconv.Itoa(result.Limit))
+		header.Set(X_RATELIMIT_REMAINING, strconv.Itoa(result.Remaining))
+		header.Set(X_RATELIMIT_RESET, ceilSeconds(result.Reset))
+		if !result.Allowed {
+			header.Set(RETRY_AFTER, ceilSeconds(result.RetryAfter))
+			c.AbortWithError(TooManyRequests.copy())
+		}
+	}
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+//refillTokens returns the tokens of the bucket refilled since the last time
+func refillTokens(tokens float64, elapsed time.Duration, rate Rate) float64 {
+	if elapsed > 0 {
+		tokens += float64(elapsed) * float64(rate.Limit) / float64(rate.Period)
+	}
+	return math.Min(tokens, float64(rate.Limit))
+}
+
+//tokenBucketResult returns the result with the tokens left
+func tokenBucketResult(rate Rate, allowed bool, tokens float64) RateLimitResult {
+	perToken := float64(rate.Period) / float64(rate.Limit)
+	result := RateLimitResult{
+		Allowed:   allowed,
+		Limit:     rate.Limit,
+		Remaining: int(tokens),
+		Reset:     time.Duration((float64(rate.Limit) - tokens) * perToken),
+	}
+	if !allowed {
+		result.RetryAfter = time.Duration((1 - tokens) * perToken)
+	}
+	return result
+}
+
+//slidingWindowResult returns the result with the count of the previous and the current window,
+//the request is counted in cur if it is allowed
+func slidingWindowResult(rate Rate, allowed bool, prev, cur int64, elapsed time.Duration) RateLimitResult {
+	period := float64(rate.Period)
+	limit := float64(rate.Limit)
+	count := float64(prev)*(period-float64(elapsed))/period + float64(cur)
+	result := RateLimitResult{
+		Allowed:   allowed,
+		Limit:     rate.Limit,
+		Remaining: int(math.Max(0, math.Floor(limit-count))),
+		Reset:     rate.Period - elapsed,
+	}
+	if allowed {
+		return result
+	}
+	if float64(cur) >= limit {
+		//wait for the next window, which is weighted by the current one
+		result.RetryAfter = rate.Period - elapsed + time.Duration(period*(1-limit/float64(cur)))
+	} else {
+		//wait for the previous window sliding out
+		result.RetryAfter = time.Duration(period*(1-(limit-float64(cur))/float64(prev))) - elapsed
+	}
+	if result.RetryAfter < 0 {
+		result.RetryAfter = 0
+	}
+	return result
+}
+
+//the interval of removing the expired keys of the MemoryLimitStore
+const memoryLimitSweep = time.Minute
+
+//MemoryLimitStore is the RateLimitStore in the memory, which is only shared in the process
+type MemoryLimitStore struct {
+	mu        sync.Mutex
+	entries   map[string]*limitEntry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type limitEntry struct {
+	//the token bucket
+	tokens float64
+	last   time.Time
+	//the sliding window
+	window    int64
+	prev, cur int64
+
+	expire time.Time
+}
+
+//NewMemoryLimitStore creates the MemoryLimitStore
+func NewMemoryLimitStore() *MemoryLimitStore {
+	return &MemoryLimitStore{
+		entries: make(map[string]*limitEntry),
+		now:     time.Now,
+	}
+}
+
+//entry returns the entry of the key, the expired entries are removed periodically
+func (s *MemoryLimitStore) entry(key string, now time.Time) (e *limitEntry, created bool) {
+	if now.Sub(s.lastSweep) >= memoryLimitSweep {
+		for k, e := range s.entries {
+			if now.After(e.expire) {
+				delete(s.entries, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	e, ok := s.entries[key]
+	if !ok {
+		e = &limitEntry{}
+		s.entries[key] = e
+	}
+	return e, !ok
+}
+
+func (s *MemoryLimitStore) TokenBucket(key string, rate Rate) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	e, created := s.entry(key, now)
+	if created {
+		e.tokens = float64(rate.Limit)
+	} else {
+		e.tokens = refillTokens(e.tokens, now.Sub(e.last), rate)
+	}
+	e.last = now
+	e.expire = now.Add(rate.Period)
+
+	allowed := e.tokens >= 1
+	if allowed {
+		e.tokens--
+	}
+	return tokenBucketResult(rate, allowed, e.tokens), nil
+}
+
+func (s *MemoryLimitStore) SlidingWindow(key string, rate Rate) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	e, _ := s.entry(key, now)
+	window := now.UnixNano() / int64(rate.Period)
+	switch window - e.window {
+	case 0:
+	case 1:
+		e.prev, e.cur = e.cur, 0
+	default:
+		e.prev, e.cur = 0, 0
+	}
+	e.window = window
+	e.expire = now.Add(2 * rate.Period)
+
+	elapsed := time.Duration(now.UnixNano() - window*int64(rate.Period))
+	period := float64(rate.Period)
+	allowed := float64(e.prev)*(period-float64(elapsed))/period+float64(e.cur) < float64(rate.Limit)
+	if allowed {
+		e.cur++
+	}
+	return slidingWindowResult(rate, allowed, e.prev, e.cur, elapsed), nil
+}
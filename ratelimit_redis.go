@@ -0,0 +1,104 @@
+package goil
+
+import (
+	"goil/helper/redis"
+	"strconv"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+//KEYS[1] is the bucket, ARGV are the limit, the period and now in milliseconds
+var tokenBucketScript = redigo.NewScript(1, `
+local limit = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens = tonumber(bucket[1])
+if tokens == nil then
+	tokens = limit
+else
+	local elapsed = math.max(0, now - tonumber(bucket[2]))
+	tokens = math.min(limit, tokens + elapsed * limit / period)
+end
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'last', now)
+redis.call('PEXPIRE', KEYS[1], period)
+return {allowed, tostring(tokens)}
+`)
+
+//KEYS are the current and the previous window, ARGV are the limit, the period and the elapsed time of the current window
+var slidingWindowScript = redigo.NewScript(2, `
+local limit = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local elapsed = tonumber(ARGV[3])
+local cur = tonumber(redis.call('GET', KEYS[1]) or 0)
+local prev = tonumber(redis.call('GET', KEYS[2]) or 0)
+local allowed = 0
+if prev * (period - elapsed) / period + cur < limit then
+	cur = redis.call('INCR', KEYS[1])
+	redis.call('PEXPIRE', KEYS[1], period * 2)
+	allowed = 1
+end
+return {allowed, prev, cur}
+`)
+
+//RedisLimitStore is the RateLimitStore on redis, which is shared by the processes,
+//the requests are counted atomically by the lua scripts
+type RedisLimitStore struct {
+	client *redis.RedisClient
+}
+
+//NewRedisLimitStore creates the RedisLimitStore:
+//	goil.NewRedisLimitStore(redis.GetRedisClient("redis://127.0.0.1:6379/1"))
+func NewRedisLimitStore(client *redis.RedisClient) *RedisLimitStore {
+	return &RedisLimitStore{client: client}
+}
+
+func (s *RedisLimitStore) TokenBucket(key string, rate Rate) (RateLimitResult, error) {
+	conn := s.client.GetConn()
+	defer conn.Close()
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	reply, err := redigo.Values(tokenBucketScript.Do(conn, key, rate.Limit, milliseconds(rate.Period), now))
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	var allowed int
+	var tokens string
+	if _, err = redigo.Scan(reply, &allowed, &tokens); err != nil {
+		return RateLimitResult{}, err
+	}
+	left, err := strconv.ParseFloat(tokens, 64)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	return tokenBucketResult(rate, allowed == 1, left), nil
+}
+
+func (s *RedisLimitStore) SlidingWindow(key string, rate Rate) (RateLimitResult, error) {
+	conn := s.client.GetConn()
+	defer conn.Close()
+	now := time.Now().UnixNano()
+	window := now / int64(rate.Period)
+	elapsed := time.Duration(now - window*int64(rate.Period))
+	reply, err := redigo.Values(slidingWindowScript.Do(conn,
+		key+":"+strconv.FormatInt(window, 10), key+":"+strconv.FormatInt(window-1, 10),
+		rate.Limit, milliseconds(rate.Period), milliseconds(elapsed)))
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	var allowed int
+	var prev, cur int64
+	if _, err = redigo.Scan(reply, &allowed, &prev, &cur); err != nil {
+		return RateLimitResult{}, err
+	}
+	return slidingWindowResult(rate, allowed == 1, prev, cur, elapsed), nil
+}
+
+func milliseconds(d time.Duration) int64 {
+	return int64(d / time.Millisecond)
+}
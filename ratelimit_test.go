@@ -0,0 +1,108 @@
+package goil
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	now := time.Unix(1000, 0)
+	store := NewMemoryLimitStore()
+	store.now = func() time.Time { return now }
+
+	app := New()
+	app.ErrorHandler = func(c *Context, err error) {
+		//the error is a copy of the shared one
+		if err == error(TooManyRequests) || !errors.Is(err, TooManyRequests) {
+			t.Errorf("the error = %#v", err)
+		}
+		DefHTTPErrorHandler(c, err)
+	}
+	app.Use(RateLimit(RateLimitConfig{
+		Rate:    Rate{Limit: 2, Period: time.Second},
+		Classes: map[string]Rate{"auth": {Limit: 1, Period: time.Minute}, "free": {}},
+		Key:     KeyByHeader("X-Api-Key"),
+		Store:   store,
+	}))
+	ok := func(c *Context) { c.Text("ok") }
+	app.GET("/users", ok)
+	app.GET("/posts", ok)
+	app.POST("/login", ok).With(RateClass("auth"))
+	app.POST("/logout", ok).With(RateClass("auth"))
+	app.GET("/health", ok).With(RateClass("free"))
+
+	request := func(method, path, key string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, nil)
+		r.Header.Set("X-Api-Key", key)
+		app.ServeHTTP(w, r)
+		return w
+	}
+
+	w := request(GET, "/users", "a")
+	if w.Code != http.StatusOK || w.Header().Get(X_RATELIMIT_LIMIT) != "2" || w.Header().Get(X_RATELIMIT_REMAINING) != "1" {
+		t.Errorf("GET /users: %d %v", w.Code, w.Header())
+	}
+	//the routes without the class share the limit
+	request(GET, "/posts", "a")
+	w = request(GET, "/users", "a")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get(RETRY_AFTER) != "1" {
+		t.Errorf("GET /users over the limit: %d %v", w.Code, w.Header())
+	}
+	//the keys are independent
+	if w = request(GET, "/users", "b"); w.Code != http.StatusOK {
+		t.Errorf("GET /users of key b: code = %d", w.Code)
+	}
+	//refilled
+	now = now.Add(500 * time.Millisecond)
+	if w = request(GET, "/users", "a"); w.Code != http.StatusOK {
+		t.Errorf("GET /users after refilling: code = %d", w.Code)
+	}
+
+	//the class
+	request(POST, "/login", "a")
+	w = request(POST, "/logout", "a")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get(RETRY_AFTER) != "60" {
+		t.Errorf("POST /logout over the limit: %d %v", w.Code, w.Header())
+	}
+	for i := 0; i < 5; i++ {
+		if w = request(GET, "/health", "a"); w.Code != http.StatusOK || w.Header().Get(X_RATELIMIT_LIMIT) != "" {
+			t.Errorf("GET /health: %d %v", w.Code, w.Header())
+		}
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	now := time.Unix(1000, 0)
+	store := NewMemoryLimitStore()
+	store.now = func() time.Time { return now }
+	rate := Rate{Limit: 4, Period: time.Minute}
+
+	for i := 0; i < 4; i++ {
+		if r, _ := store.SlidingWindow("k", rate); !r.Allowed || r.Remaining != 3-i {
+			t.Fatalf("request %d: %+v", i, r)
+		}
+	}
+	r, _ := store.SlidingWindow("k", rate)
+	if r.Allowed || r.RetryAfter != 20*time.Second {
+		t.Errorf("over the limit: %+v", r)
+	}
+	//the previous window weights 2/3 after 20s
+	now = now.Add(40 * time.Second)
+	for i := 0; i < 2; i++ {
+		if r, _ = store.SlidingWindow("k", rate); !r.Allowed || r.Remaining != 0 {
+			t.Errorf("request %d in the next window: %+v", i, r)
+		}
+	}
+	if r, _ = store.SlidingWindow("k", rate); r.Allowed || r.RetryAfter != 10*time.Second {
+		t.Errorf("over the limit in the next window: %+v", r)
+	}
+	//the windows are outdated
+	now = now.Add(3 * time.Minute)
+	if r, _ = store.SlidingWindow("k", rate); !r.Allowed || r.Remaining != 3 {
+		t.Errorf("after the windows: %+v", r)
+	}
+}
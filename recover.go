@@ -0,0 +1,121 @@
+package goil
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"strings"
+)
+
+//the max bytes of the stack logged by default
+const DEFAULT_STACK_SIZE = 4 << 10
+
+//PanicReporter reports the panic to the sink such as the error tracking service,
+//the stack is nil for the broken connections
+type PanicReporter func(c *Context, err interface{}, stack []byte)
+
+//RecoverConfig configures the middleware created by RecoverWithConfig
+type RecoverConfig struct {
+	//Handler responds the panic instead of recording the HTTPError for the App.ErrorHandler
+	Handler func(c *Context, err interface{})
+	//the status code of the HTTPError recorded, 500 is used if it is zero
+	StatusCode int
+	//the max bytes of the stack logged, DEFAULT_STACK_SIZE is used if it is zero,
+	//and the stack isn't logged if it is negative
+	StackSize int
+	//RepanicAbortHandler panics http.ErrAbortHandler again,
+	//so the http server aborts the response without logging
+	RepanicAbortHandler bool
+	//Reporter receives the panics besides logging
+	Reporter PanicReporter
+}
+
+//Recover recovers the panics of the handlers, the panic is logged and recorded as a 500 HTTPError,
+//so the App.ErrorHandler responds 500 unless the response has been written
+func Recover() HandlerFunc {
+	return RecoverWithConfig(RecoverConfig{})
+}
+
+//RecoverWithConfig returns the Recover middleware with the config
+func RecoverWithConfig(conf RecoverConfig) HandlerFunc {
+	if conf.StatusCode == 0 {
+		conf.StatusCode = http.StatusInternalServerError
+	}
+	if conf.StackSize == 0 {
+		conf.StackSize = DEFAULT_STACK_SIZE
+	}
+	return func(c *Context) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler && conf.RepanicAbortHandler {
+				panic(err)
+			}
+
+			l := c.Logger()
+			var fontColor, reset string
+			if l.IsTTY() {
+				fontColor = redFont
+				reset = resetClr
+			}
+			//the client has gone, nothing can be written
+			if brokenPipe(err) {
+				l.Printf("%s[recover] %s %s: %s%s", fontColor, c.Request.Method, c.Request.URL.Path, err, reset)
+				if conf.Reporter != nil {
+					conf.Reporter(c, err, nil)
+				}
+				c.Abort()
+				return
+			}
+
+			var stack []byte
+			if conf.StackSize > 0 {
+				stack = stackInfo(3)
+				if len(stack) > conf.StackSize {
+					stack = stack[:conf.StackSize]
+				}
+			}
+			reqInfo, _ := httputil.DumpRequest(c.Request, false)
+			l.Printf("%s[recover] %s\n%s\n%s%s", fontColor, err, string(reqInfo), string(stack), reset)
+			if conf.Reporter != nil {
+				conf.Reporter(c, err, stack)
+			}
+			c.Abort()
+			if conf.Handler != nil {
+				conf.Handler(c, err)
+				return
+			}
+			internal, ok := err.(error)
+			if !ok {
+				internal = fmt.Errorf("%v", err)
+			}
+			c.Error(NewHTTPError(conf.StatusCode).SetInternal(internal))
+			//the outer middlewares go on with the error response
+			c.handleError()
+		}()
+		c.Next()
+	}
+}
+
+//brokenPipe checks whether the panic is caused by the broken connection
+func brokenPipe(err interface{}) bool {
+	e, ok := err.(error)
+	if !ok {
+		return false
+	}
+	var ne *net.OpError
+	if !errors.As(e, &ne) {
+		return false
+	}
+	var se *os.SyscallError
+	if errors.As(ne, &se) {
+		msg := strings.ToLower(se.Error())
+		return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "connection reset by peer")
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package goil
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestRecoverWithConfig(t *testing.T) {
+	var reported []interface{}
+	var stackLen int
+	app := New()
+	app.Use(RecoverWithConfig(RecoverConfig{
+		StatusCode: http.StatusServiceUnavailable,
+		StackSize:  64,
+		Reporter: func(c *Context, err interface{}, stack []byte) {
+			reported = append(reported, err)
+			stackLen = len(stack)
+		},
+	}))
+	app.GET("/panic", func(c *Context) {
+		panic("boom")
+	})
+	app.GET("/pipe", func(c *Context) {
+		panic(&net.OpError{Op: "write", Err: os.NewSyscallError("write", syscall.EPIPE)})
+	})
+
+	if w := serve(app, GET, "/panic"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET /panic = %d; want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if len(reported) != 1 || reported[0] != "boom" || stackLen == 0 || stackLen > 64 {
+		t.Errorf("reported %v with %d bytes stack", reported, stackLen)
+	}
+	//nothing is written for the broken connection
+	if w := serve(app, GET, "/pipe"); w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("GET /pipe = %d %q", w.Code, w.Body.String())
+	}
+	if len(reported) != 2 || stackLen != 0 {
+		t.Errorf("the broken pipe should be reported without stack")
+	}
+
+	app = New()
+	app.Use(RecoverWithConfig(RecoverConfig{
+		Handler: func(c *Context, err interface{}) {
+			c.Status(http.StatusBadGateway)
+			c.Text("custom")
+		},
+		RepanicAbortHandler: true,
+	}))
+	app.GET("/panic", func(c *Context) {
+		panic("boom")
+	})
+	app.GET("/abort", func(c *Context) {
+		panic(http.ErrAbortHandler)
+	})
+	if w := serve(app, GET, "/panic"); w.Code != http.StatusBadGateway || w.Body.String() != "custom" {
+		t.Errorf("GET /panic = %d %q", w.Code, w.Body.String())
+	}
+	func() {
+		defer func() {
+			if err := recover(); err != http.ErrAbortHandler {
+				t.Errorf("http.ErrAbortHandler should be panicked again, got %v", err)
+			}
+		}()
+		serve(app, GET, "/abort")
+	}()
+}
@@ -1,23 +1,84 @@
 package goil
 
 import (
+	"bytes"
+	"fmt"
 	"goil/logger"
 	"html/template"
 	"io/ioutil"
+	"sync"
+	"sync/atomic"
 )
 
 type FuncMap = map[string]interface{}
 
 type HtmlTemp struct {
 	T *template.Template
+	//the names of the funcs added by Method and Methods, they aren't replaced by the funcs of the request
+	methods map[string]bool
+	//changed by the methods of HtmlTemp, the clones of the older version are dropped
+	version uint64
+	//the clones of T bound to the funcs of the request
+	clones sync.Pool
 }
 
 //type assert
 var _ Render = new(HtmlTemp)
 
+//tempClone is a clone of the templates whose funcs read the ViewModel rendering
+type tempClone struct {
+	t       *template.Template
+	vm      ViewModel
+	version uint64
+}
+
+var tempBufPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+//Render executes the template cloned with the template funcs of the request,
+//such as url of the app and cspNonce of the Secure middleware, so h.T itself shouldn't be executed.
+//the clones are reused, so the templates should be added before serving.
+//the response is written only if the template is executed successfully
 func (h *HtmlTemp) Render(w Response, content interface{}) error {
 	vm := content.(ViewModel)
-	return h.T.ExecuteTemplate(w, vm.Name, vm.Model)
+	tc, err := h.clone(vm.Name)
+	if err != nil {
+		return err
+	}
+	buf := tempBufPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer tempBufPool.Put(buf)
+	tc.vm = vm
+	err = tc.t.ExecuteTemplate(buf, vm.Name, vm.Model)
+	tc.vm = ViewModel{}
+	h.clones.Put(tc)
+	if err != nil {
+		return err
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+//clone returns a clone of the current version containing the template
+func (h *HtmlTemp) clone(name string) (*tempClone, error) {
+	version := atomic.LoadUint64(&h.version)
+	if tc, ok := h.clones.Get().(*tempClone); ok && tc.version == version && tc.t.Lookup(name) != nil {
+		return tc, nil
+	}
+	t, err := h.T.Clone()
+	if err != nil {
+		return nil, err
+	}
+	tc := &tempClone{t: t, version: version}
+	funcs := tc.vm.funcs()
+	for name := range funcs {
+		if h.methods[name] {
+			delete(funcs, name)
+		}
+	}
+	t.Funcs(funcs)
+	return tc, nil
 }
 
 func (h *HtmlTemp) ContentType() string {
@@ -25,20 +86,34 @@ func (h *HtmlTemp) ContentType() string {
 }
 
 func (h *HtmlTemp) Method(name string, m interface{}) *HtmlTemp {
-	h.T.Funcs(FuncMap{name: m})
-	return h
+	return h.Methods(FuncMap{name: m})
 }
 
+//Methods adds the template funcs, the funcs named url, cspNonce, csrfToken or csrfField
+//are used instead of the ones of the request
 func (h *HtmlTemp) Methods(ms FuncMap) *HtmlTemp {
+	if h.methods == nil {
+		h.methods = make(map[string]bool, len(ms))
+	}
+	for name := range ms {
+		h.methods[name] = true
+	}
 	h.T.Funcs(ms)
+	h.changed()
 	return h
 }
 
 func (h *HtmlTemp) Delims(left, right string) *HtmlTemp {
 	h.T.Delims(left, right)
+	h.changed()
 	return h
 }
 
+//changed drops the clones of the templates
+func (h *HtmlTemp) changed() {
+	atomic.AddUint64(&h.version, 1)
+}
+
 func (h *HtmlTemp) Temp(name, filepath string) *HtmlTemp {
 	byts, err := ioutil.ReadFile(filepath)
 	if err != nil {
@@ -50,6 +125,7 @@ func (h *HtmlTemp) Temp(name, filepath string) *HtmlTemp {
 		logger.Errorf("when add html template named %s: %s", name, err)
 		return h
 	}
+	h.changed()
 	return h
 }
 
@@ -58,15 +134,33 @@ func (h *HtmlTemp) Temps(filepath ...string) *HtmlTemp {
 	if err != nil {
 		logger.Errorf("when add html templates: %s", err)
 	}
+	h.changed()
 	return h
 }
 
+//HtmlRender is the default html templates of the apps, see WithHtmlRender
 var HtmlRender *HtmlTemp
 
 func init() {
-	HtmlRender = &HtmlTemp{
+	HtmlRender = NewHtmlTemp()
+}
+
+//NewHtmlTemp creates the html templates with the template funcs url, cspNonce, csrfToken and csrfField,
+//they can be replaced by Method and Methods
+func NewHtmlTemp() *HtmlTemp {
+	h := &HtmlTemp{
 		T: template.New(""),
 	}
+	//the funcs are bound to the request when rendering
+	h.T.Funcs(new(ViewModel).funcs())
+	return h
+}
+
+//the template func url generates the url by the route name of the app rendering the template, for example:
+//	<a href="{{url "user.show" .ID}}">
+//noURL is used when the template isn't rendered by Context.Html
+func noURL(name string, params ...interface{}) (string, error) {
+	return "", fmt.Errorf("no route named %s", name)
 }
 
 func TempMethod(name string, fun interface{}) {
@@ -91,6 +185,40 @@ func Temps(filepath ...string) {
 type ViewModel struct {
 	Name  string
 	Model interface{}
+	//generate the url by the route name of the app
+	url func(name string, params ...interface{}) (string, error)
+	//the nonce of the Content-Security-Policy
+	nonce string
+	//the form field and the token of the CSRF middleware
+	csrfName  string
+	csrfToken string
+}
+
+//csrfField returns the hidden input of the csrf token
+func (vm ViewModel) csrfField() string {
+	if vm.csrfToken == "" {
+		return ""
+	}
+	return `<input type="hidden" name="` + template.HTMLEscapeString(vm.csrfName) +
+		`" value="` + template.HTMLEscapeString(vm.csrfToken) + `">`
+}
+
+//funcs returns the template funcs reading the request rendering vm:
+//	<script nonce="{{cspNonce}}">
+//	<meta name="csrf-token" content="{{csrfToken}}">
+//	<form method="post">{{csrfField}}...</form>
+func (vm *ViewModel) funcs() FuncMap {
+	return FuncMap{
+		"url": func(name string, params ...interface{}) (string, error) {
+			if vm.url == nil {
+				return noURL(name, params...)
+			}
+			return vm.url(name, params...)
+		},
+		"cspNonce":  func() string { return vm.nonce },
+		"csrfToken": func() string { return vm.csrfToken },
+		"csrfField": func() template.HTML { return template.HTML(vm.csrfField()) },
+	}
 }
 
 func VM(name string, data interface{}) ViewModel {
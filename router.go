@@ -9,9 +9,11 @@ package goil
 
 import (
 	"fmt"
-	"goil/logger"
 	"net/http"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 //HTTP METHOD TYPE
@@ -58,26 +60,55 @@ type IRouter interface {
 	ANY(path string, handlers ...HandlerFunc) IRouter
 	Static(path string, filepath string) IRouter
 	StaticFS(path string, fs http.FileSystem) IRouter
+	Name(name string) IRouter
+	Mount(path string, handler http.Handler) IRouter
+	With(opts ...RouteOption) IRouter
 }
 
+//methodTree holds the root of the radix tree,
+//the root is replaced atomically when adding or removing routes at runtime
 type methodTree struct {
-	*node
+	root   atomic.Value
 	method string
 }
 
+func (t *methodTree) load() *node {
+	root, _ := t.root.Load().(*node)
+	return root
+}
+
+func (t *methodTree) store(root *node) {
+	t.root.Store(root)
+}
+
 func (t *methodTree) isNil() bool {
-	return t.node == nil
+	return t.load() == nil
 }
 
 type router struct {
 	*group
 	trees map[string]*methodTree
+	//the host pattern of the router, empty for the default router
+	host string
+	//map the route name to the path pattern, it is read while serving by App.URL
+	names *concurrentMap
+	//the registered routes in order
+	routes []*Route
+	//serialize the runtime modification, and protect the routes
+	mu sync.RWMutex
+	//the config and the registration guard of the app
+	conf  *Config
+	guard *Guard
 }
 
 type group struct {
 	middlewares HandlerChain
-	router      *router
-	base        string
+	//the group whose middlewares run firstly, the host routers inherit the app's by it
+	parent *group
+	router *router
+	base   string
+	//the routes registered by the last call of the group
+	last []*Route
 }
 
 func (r *router) findTree(method string) (*methodTree, bool) {
@@ -87,35 +118,69 @@ func (r *router) findTree(method string) (*methodTree, bool) {
 	return nil, false
 }
 
-func (r *router) route(method, path string) (chain HandlerChain, params Params, tsr bool) {
+func (r *router) route(method, path string) (chain HandlerChain, params Params, tsr bool, route *Route) {
 	tree, exist := r.findTree(method)
+	//unsupported method, leave the chain nil and let the caller
+	//check the other method trees
 	if !exist {
-		//return the not method found handler
-		chain = append(r.middlewares, NotMethodHandler)
 		return
 	}
-	//404 not found
-	if tree.isNil() {
-		chain = append(r.middlewares, NotFoundHandler)
+	//404 not found, leave the chain nil and let the caller decide
+	//whether to redirect or not
+	root := tree.load()
+	if root == nil {
 		return
 	}
-	chain, params, tsr = tree.routerMapping(path)
-	//404 not found
+	chain, params, tsr, route = root.routerMapping(path)
 	if len(chain) == 0 {
-		chain = append(r.middlewares, NotFoundHandler)
-		return
+		chain = nil
 	}
 	return
 }
 
+//fixPath try to find the registered path which matches the path case-insensitively
+//the path should be cleaned by CleanPath before calling
+func (r *router) fixPath(method, path string, fixTrailingSlash bool) (string, bool) {
+	tree, exist := r.findTree(method)
+	if !exist || tree.isNil() {
+		return "", false
+	}
+	return tree.load().findCaseInsensitivePath(path, fixTrailingSlash)
+}
+
+//allowed returns the methods which have registered a handler for the path except reqMethod,
+//the result is sorted and can be used as the value of the Allow header directly.
+//the path "*" matches all methods which have routes registered
+func (r *router) allowed(path, reqMethod string) []string {
+	allows := make([]string, 0, len(r.trees))
+	for method, tree := range r.trees {
+		root := tree.load()
+		if method == reqMethod || root == nil {
+			continue
+		}
+		if path == "*" {
+			allows = append(allows, method)
+			continue
+		}
+		if chain, _, _, _ := root.routerMapping(path); chain != nil {
+			allows = append(allows, method)
+		}
+	}
+	sort.Strings(allows)
+	return allows
+}
+
 //assert *router and *group implements IRouter interface
 var _ IRouter = &router{}
 var _ IRouter = &group{}
 
-func newRouter() (r *router) {
+func newRouter(conf *Config, guard *Guard) (r *router) {
 	r = &router{
 		trees: make(map[string]*methodTree, len(methods)),
 		group: &group{},
+		names: cmNil.new(),
+		conf:  conf,
+		guard: guard,
 	}
 
 	for k, _ := range methods {
@@ -129,9 +194,17 @@ func newRouter() (r *router) {
 	return
 }
 
+//chain returns the middlewares of the group after the ones of the parent
+func (g *group) chain() HandlerChain {
+	if g.parent == nil {
+		return g.middlewares
+	}
+	return combineChain(g.parent.chain(), g.middlewares...)
+}
+
 func (g *group) Group(path string, handlers ...HandlerFunc) IRouter {
 	return &group{
-		middlewares: combineChain(g.middlewares, handlers...),
+		middlewares: combineChain(g.chain(), handlers...),
 		router:      g.router,
 		base:        joinPath(g.base, path),
 	}
@@ -142,21 +215,30 @@ func (g *group) Use(handlers ...HandlerFunc) IRouter {
 	return g
 }
 
-func (r *router) add(method string, path string, chain HandlerChain) {
+func (r *router) add(method string, path string, chain HandlerChain, handlerName string) (route *Route) {
 
 	assert1(len(path) > 0 && path[0] == '/', fmt.Sprintf("path must start with '/'"))
 	assert1(chain != nil, fmt.Sprintf("the handler of %s is nil", path))
 	tree, exists := r.findTree(method)
 	assert1(exists, fmt.Sprintf("unsupported method:%s", method))
-	guard.execSafely(func() {
+	r.guard.execSafely(func() {
 		if tree.isNil() {
-			tree.node = &node{
-				pattern: "/",
-				typ:     static,
-			}
+			tree.store(newRoot())
+		}
+		n := tree.load().addNode(path, chain, r.conf)
+		route = &Route{
+			Host:        r.host,
+			Method:      method,
+			Pattern:     path,
+			handlerName: handlerName,
+			handlerNum:  len(chain),
 		}
-		tree.addNode(path, chain)
+		n.route = route
+		r.mu.Lock()
+		r.routes = append(r.routes, route)
+		r.mu.Unlock()
 	})
+	return
 }
 
 func (g *group) ADD(method string, path string, handlers ...HandlerFunc) IRouter {
@@ -164,12 +246,15 @@ func (g *group) ADD(method string, path string, handlers ...HandlerFunc) IRouter
 		panic(fmt.Sprintf("handler nil:%s", path))
 	}
 	absolutePath := joinPath(g.base, path)
-	chain := combineChain(g.middlewares, handlers...)
-	g.router.add(method, absolutePath, chain)
-	if RunMode() == DBG {
-		handlerNum := len(chain)
-		handlerName := funcName(chain[handlerNum-1])
-		printRouteInfo(method, absolutePath, handlerName, handlerNum)
+	chain := combineChain(g.chain(), handlers...)
+	handlerNum := len(chain)
+	handlerName := funcName(chain[handlerNum-1])
+	g.last = g.last[:0:0]
+	if route := g.router.add(method, absolutePath, chain, handlerName); route != nil {
+		g.last = append(g.last, route)
+	}
+	if g.router.conf.mode() == DBG {
+		g.router.printRouteInfo(method, absolutePath, handlerName, handlerNum)
 	}
 	return g
 }
@@ -261,7 +346,32 @@ func (g *group) StaticFS(path string, fs http.FileSystem) IRouter {
 		rawHandler.ServeHTTP(c.Response, c.Request)
 	}
 	g.GET(fullPath, handler)
+	last := g.last
 	g.HEAD(fullPath, handler)
+	//Name and With apply to both routes
+	g.last = append(last, g.last...)
+	return g
+}
+
+//Mount serves all the requests under the path by the handler, such as an existing net/http mux
+//or another *App, the path prefix is stripped before calling the handler.
+//the middlewares of the group are executed before the handler
+func (g *group) Mount(path string, handler http.Handler) IRouter {
+	prefix := joinPath(g.base, path)
+	if strings.Contains(prefix, ":") || strings.Contains(prefix, "*") {
+		panic("the mount path can't contain path params")
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	rel := strings.TrimSuffix(path, "/")
+	mounted := mountHandler(prefix, handler)
+	var last []*Route
+	if prefix != "" {
+		g.ANY(rel, mounted)
+		last = g.last
+	}
+	g.ANY(joinPath(rel, "/*mountpath"), mounted)
+	//With applies to all the routes
+	g.last = append(last, g.last...)
 	return g
 }
 
@@ -270,18 +380,154 @@ func (g *group) ANY(path string, handlers ...HandlerFunc) IRouter {
 		panic(fmt.Sprintf("handler nil:%s", path))
 	}
 
+	last := make([]*Route, 0, len(methods))
 	for k := range methods {
 		g.ADD(k, path, handlers...)
+		last = append(last, g.last...)
+	}
+	g.last = last
+
+	return g
+}
+
+//snapshot returns a copy of the registered routes
+func (r *router) snapshot() []*Route {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	routes := make([]*Route, len(r.routes))
+	copy(routes, r.routes)
+	return routes
+}
+
+//addRoute adds the route at runtime, the tree is copied and replaced atomically,
+//so the serving requests never see a half-modified tree
+func (r *router) addRoute(method, path string, chain HandlerChain, opts ...RouteOption) (route *Route, err error) {
+	tree, exists := r.findTree(method)
+	if !exists {
+		return nil, fmt.Errorf("unsupported method:%s", method)
 	}
+	if len(path) == 0 || path[0] != '/' {
+		return nil, fmt.Errorf("path must start with '/'")
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("the handler of %s is nil", path)
+	}
+	route = &Route{
+		Host:        r.host,
+		Method:      method,
+		Pattern:     path,
+		handlerName: funcName(chain[len(chain)-1]),
+		handlerNum:  len(chain),
+	}
+	for _, opt := range opts {
+		opt(route)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	root := newRoot()
+	if old := tree.load(); old != nil {
+		root = old.clone()
+	}
+	//addNode panics when the path conflicts
+	defer func() {
+		if e := recover(); e != nil {
+			route, err = nil, fmt.Errorf("%v", e)
+		}
+	}()
+	n := root.addNode(path, chain, r.conf)
+	n.route = route
+	tree.store(root)
+	r.routes = append(r.routes, route)
+	return
+}
 
+//removeRoute removes the route at runtime by the pattern used to register it
+func (r *router) removeRoute(method, path string) error {
+	tree, exists := r.findTree(method)
+	if !exists {
+		return fmt.Errorf("unsupported method:%s", method)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old := tree.load()
+	if old == nil {
+		return fmt.Errorf("no route for %s %s", method, path)
+	}
+	root := old.clone()
+	if !root.removeNode(path) {
+		return fmt.Errorf("no route for %s %s", method, path)
+	}
+	tree.store(root)
+	name := ""
+	for i, route := range r.routes {
+		if route.Method == method && route.Pattern == path {
+			name = route.Name
+			r.routes = append(r.routes[:i:i], r.routes[i+1:]...)
+			break
+		}
+	}
+	//the name is kept while other routes have it, such as HEAD of StaticFS
+	if name != "" {
+		for _, route := range r.routes {
+			if route.Name == name {
+				return nil
+			}
+		}
+		r.names.del(name)
+	}
+	return nil
+}
+
+//Name names the routes registered by the last call of the group, such as both GET and HEAD of StaticFS,
+//the name can be used to generate the url by App.URL
+func (g *group) Name(name string) IRouter {
+	g.router.name(name, g.last)
+	return g
+}
+
+//With applies the options to the routes registered by the last call of the group
+func (g *group) With(opts ...RouteOption) IRouter {
+	g.router.guard.execSafely(func() {
+		for _, route := range g.last {
+			for _, opt := range opts {
+				opt(route)
+			}
+		}
+	})
 	return g
 }
 
-func printRouteInfo(method, path, handlerName string, handlerNum int) {
+func (r *router) name(name string, routes []*Route) {
+	assert1(name != "", "the route name can't be empty")
+	r.guard.execSafely(func() {
+		assert1(len(routes) > 0, fmt.Sprintf("no route to name %s", name))
+		path := routes[0].Pattern
+		if p, exists := r.names.get(name); exists && p != path {
+			panic(fmt.Sprintf("the route name %s is already used by '%s'", name, p))
+		}
+		r.names.set(name, path)
+		for _, route := range routes {
+			route.Name = name
+		}
+	})
+}
+
+//url generates the url of the route named name, the params are filled into the path params in order
+func (r *router) url(name string, params ...interface{}) (string, error) {
+	path, exists := r.names.get(name)
+	if !exists {
+		return "", fmt.Errorf("no route named %s", name)
+	}
+	return fillPath(path.(string), params...)
+}
+
+func (r *router) printRouteInfo(method, path, handlerName string, handlerNum int) {
+	l := r.conf.logger()
 	var methodColor, resetColor string
-	if logger.IsTTY() {
+	if l.IsTTY() {
 		methodColor = colorForMethod(method)
 		resetColor = resetClr
 	}
-	logger.Printf("[router] %s %-6s%s %-25s ==> %s (%d handlers)", methodColor, method, resetColor, path, handlerName, handlerNum)
+	l.Printf("[router] %s %-6s%s %-25s ==> %s (%d handlers)", methodColor, method, resetColor, path, handlerName, handlerNum)
 }
@@ -0,0 +1,156 @@
+package goil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+const (
+	ROUTES_TABLE = "table"
+	ROUTES_JSON  = "json"
+)
+
+//Route is the registered route, it can be read by Context.Route in the handlers
+type Route struct {
+	//the host pattern, empty for the default router
+	Host   string
+	Method string
+	//the path pattern registered, such as /users/:id
+	Pattern string
+	//the name set by Name
+	Name string
+	//the metadata attached by the RouteOption
+	Meta map[string]interface{}
+
+	handlerName string
+	handlerNum  int
+	//the max bytes of the request body set by BodyLimit
+	bodyLimit int64
+}
+
+//Get returns the metadata of the route
+func (r *Route) Get(key string) (val interface{}, exists bool) {
+	if r == nil {
+		return nil, false
+	}
+	val, exists = r.Meta[key]
+	return
+}
+
+//RouteOption attaches metadata to the routes, it is applied by With after registering:
+//	app.GET("/users", listUsers).With(goil.Meta("scopes", []string{"user:read"}))
+type RouteOption func(*Route)
+
+const META_SUMMARY = "summary"
+
+//Meta attaches the key-value metadata to the route
+func Meta(key string, value interface{}) RouteOption {
+	return func(r *Route) {
+		if r.Meta == nil {
+			r.Meta = make(map[string]interface{})
+		}
+		r.Meta[key] = value
+	}
+}
+
+//Summary attaches the doc summary to the route
+func Summary(summary string) RouteOption {
+	return Meta(META_SUMMARY, summary)
+}
+
+//BodyLimit sets the max bytes of the request body for the route instead of Config.MaxBodySize,
+//the negative size means no limit
+func BodyLimit(size int64) RouteOption {
+	return func(r *Route) {
+		r.bodyLimit = size
+	}
+}
+
+//RouteInfo describes a registered route
+type RouteInfo struct {
+	Host        string `json:"host,omitempty"`
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Handler     string `json:"handler"`
+	Middlewares int    `json:"middlewares"`
+	Name        string `json:"name,omitempty"`
+}
+
+//Routes returns the registered routes sorted by host, path and method
+func (app *App) Routes() []RouteInfo {
+	routes := app.router.snapshot()
+	for _, h := range app.hosts {
+		routes = append(routes, h.snapshot()...)
+	}
+	infos := make([]RouteInfo, 0, len(routes))
+	for _, rt := range routes {
+		infos = append(infos, RouteInfo{
+			Host:        rt.Host,
+			Method:      rt.Method,
+			Path:        rt.Pattern,
+			Handler:     rt.handlerName,
+			Middlewares: rt.handlerNum - 1,
+			Name:        rt.Name,
+		})
+	}
+	sort.SliceStable(infos, func(i, j int) bool {
+		if infos[i].Host != infos[j].Host {
+			return infos[i].Host < infos[j].Host
+		}
+		if infos[i].Path != infos[j].Path {
+			return infos[i].Path < infos[j].Path
+		}
+		return infos[i].Method < infos[j].Method
+	})
+	return infos
+}
+
+//DumpRoutes writes the routes to w as a table or json,
+//the output is stable and can be diffed between releases
+func (app *App) DumpRoutes(w io.Writer, format string) error {
+	routes := app.Routes()
+	switch format {
+	case ROUTES_JSON:
+		byts, err := json.MarshalIndent(routes, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", byts)
+		return err
+	case ROUTES_TABLE, "":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "HOST\tMETHOD\tPATH\tNAME\tHANDLER\tMIDDLEWARES")
+		for _, rt := range routes {
+			host := rt.Host
+			if host == "" {
+				host = "*"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\n", host, rt.Method, rt.Path, rt.Name, rt.Handler, rt.Middlewares)
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("unsupported routes format: %s", format)
+}
+
+//RoutesCommand handles the command line `routes [table|json]`, it dumps the routes to stdout
+//and returns true if the args is the routes command, so the app can exit without serving:
+//	if app.RoutesCommand(os.Args[1:]) {
+//		return
+//	}
+func (app *App) RoutesCommand(args []string) bool {
+	if len(args) == 0 || args[0] != "routes" {
+		return false
+	}
+	format := ROUTES_TABLE
+	if len(args) > 1 {
+		format = args[1]
+	}
+	if err := app.DumpRoutes(os.Stdout, format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+package goil
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func listUsers(c *Context) {}
+
+func TestRoutes(t *testing.T) {
+	app := New()
+	auth := func(c *Context) { c.Next() }
+	app.Use(auth)
+	app.GET("/users", listUsers).Name("user.list")
+	app.Group("/v1", auth).POST("/users", listUsers)
+
+	want := []RouteInfo{
+		{Method: GET, Path: "/users", Handler: "goil.listUsers", Middlewares: 1, Name: "user.list"},
+		{Method: POST, Path: "/v1/users", Handler: "goil.listUsers", Middlewares: 2},
+	}
+	if routes := app.Routes(); !reflect.DeepEqual(routes, want) {
+		t.Errorf("Routes() = %+v; want %+v", routes, want)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := app.DumpRoutes(buf, ROUTES_JSON); err != nil {
+		t.Fatal(err)
+	}
+	var dumped []RouteInfo
+	if err := json.Unmarshal(buf.Bytes(), &dumped); err != nil || !reflect.DeepEqual(dumped, want) {
+		t.Errorf("DumpRoutes(json) = %s, %v", buf.String(), err)
+	}
+
+	buf.Reset()
+	if err := app.DumpRoutes(buf, ROUTES_TABLE); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "*") || !strings.Contains(lines[1], "GET") || !strings.Contains(lines[1], "user.list") {
+		t.Errorf("DumpRoutes(table) = %s", buf.String())
+	}
+
+	if err := app.DumpRoutes(buf, "yaml"); err == nil {
+		t.Error("DumpRoutes(yaml) should fail")
+	}
+}
+
+func TestRouteMeta(t *testing.T) {
+	app := New()
+	var patterns []string
+	var scopes []interface{}
+	app.Use(func(c *Context) {
+		c.Next()
+		route := c.Route()
+		if route == nil {
+			patterns = append(patterns, "")
+			return
+		}
+		patterns = append(patterns, route.Pattern)
+		scope, _ := route.Get("scope")
+		scopes = append(scopes, scope)
+	})
+	h := func(c *Context) {}
+	app.GET("/users/:id", h).With(Meta("scope", "user:read"), Summary("show the user")).Name("user.show")
+	app.ANY("/ping", h).With(Meta("scope", "public"))
+	app.XRouter().POST("/users", func() string { return "" }).With(Meta("scope", "user:write"))
+
+	serve(app, GET, "/users/10")
+	serve(app, DELETE, "/ping")
+	serve(app, POST, "/users")
+	serve(app, GET, "/nope")
+
+	wantPatterns := []string{"/users/:id", "/ping", "/users", ""}
+	wantScopes := []interface{}{"user:read", "public", "user:write"}
+	if !reflect.DeepEqual(patterns, wantPatterns) || !reflect.DeepEqual(scopes, wantScopes) {
+		t.Errorf("patterns = %v, scopes = %v", patterns, scopes)
+	}
+
+	for _, rt := range app.router.routes {
+		if rt.Pattern == "/users/:id" && (rt.Name != "user.show" || rt.Meta[META_SUMMARY] != "show the user") {
+			t.Errorf("route = %+v", rt)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"goil"
+	"os"
 )
 
 func main() {
@@ -21,6 +22,10 @@ func main() {
 	xrouter.GET("/greet/:who", func(p *Params) string {
 		return "hello," + p.Who
 	})
+	//dump the routes by `sample routes [table|json]`
+	if app.RoutesCommand(os.Args[1:]) {
+		return
+	}
 	if err := app.Run(":8080"); err != nil {
 		panic(err)
 	}
@@ -0,0 +1,153 @@
+package goil
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	STRICT_TRANSPORT_SECURITY           = "Strict-Transport-Security"
+	X_FRAME_OPTIONS                     = "X-Frame-Options"
+	X_CONTENT_TYPE_OPTIONS              = "X-Content-Type-Options"
+	REFERRER_POLICY                     = "Referrer-Policy"
+	CONTENT_SECURITY_POLICY             = "Content-Security-Policy"
+	CONTENT_SECURITY_POLICY_REPORT_ONLY = "Content-Security-Policy-Report-Only"
+)
+
+//the max age of HSTS by default
+const DEFAULT_HSTS_MAX_AGE = 365 * 24 * time.Hour
+
+//CSP_NONCE is replaced by the nonce of the request in the ContentSecurityPolicy:
+//	"script-src 'self' 'nonce-{nonce}'"
+const CSP_NONCE = "{nonce}"
+
+//the key of the nonce stored in the context
+const cspNonceKey = "goil.cspNonce"
+
+//SecureConfig configures the middleware created by Secure,
+//the headers with the value "-" aren't sent
+type SecureConfig struct {
+	//SSLRedirect redirects the HTTP requests to HTTPS
+	SSLRedirect bool
+	//the host redirected to, the host of the request is used if it is empty
+	SSLHost string
+	//the max age of Strict-Transport-Security sent with HTTPS responses,
+	//DEFAULT_HSTS_MAX_AGE is used if it is zero, and it isn't sent if it is negative
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	//X-Frame-Options, DENY is used if it is empty
+	FrameOptions string
+	//X-Content-Type-Options, nosniff is used if it is empty
+	ContentTypeOptions string
+	//Referrer-Policy, strict-origin-when-cross-origin is used if it is empty
+	ReferrerPolicy string
+	//Content-Security-Policy, it isn't sent if it is empty.
+	//a new nonce is generated for every request if it contains CSP_NONCE,
+	//the nonce can be read by Context.CSPNonce and the template func cspNonce:
+	//	<script nonce="{{cspNonce}}">
+	ContentSecurityPolicy string
+	//CSPReportOnly sends Content-Security-Policy-Report-Only instead
+	CSPReportOnly bool
+}
+
+//Secure returns the middleware setting the security headers and redirecting HTTP to HTTPS,
+//the scheme of the request is checked by Context.Scheme, so the proto forwarded by the trusted proxies is respected.
+//in DBG mode neither the redirect nor HSTS is applied, so the app can be developed over plain HTTP
+func Secure(conf SecureConfig) HandlerFunc {
+	if conf.HSTSMaxAge == 0 {
+		conf.HSTSMaxAge = DEFAULT_HSTS_MAX_AGE
+	}
+	hsts := ""
+	if conf.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.FormatInt(int64(conf.HSTSMaxAge/time.Second), 10)
+		if conf.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if conf.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+	headers := map[string]string{
+		X_FRAME_OPTIONS:        secureHeader(conf.FrameOptions, "DENY"),
+		X_CONTENT_TYPE_OPTIONS: secureHeader(conf.ContentTypeOptions, "nosniff"),
+		REFERRER_POLICY:        secureHeader(conf.ReferrerPolicy, "strict-origin-when-cross-origin"),
+	}
+	cspHeader := CONTENT_SECURITY_POLICY
+	if conf.CSPReportOnly {
+		cspHeader = CONTENT_SECURITY_POLICY_REPORT_ONLY
+	}
+	nonce := strings.Contains(conf.ContentSecurityPolicy, CSP_NONCE)
+
+	return func(c *Context) {
+		https := c.Scheme() == "https"
+		dbg := c.config().mode() == DBG
+		if conf.SSLRedirect && !https && !dbg {
+			host := conf.SSLHost
+			if host == "" {
+				host = c.Host()
+			}
+			u := *c.Request.URL
+			u.Scheme = "https"
+			u.Host = host
+			//keep the method and body unchanged except GET and HEAD
+			code := http.StatusMovedPermanently
+			if c.Request.Method != GET && c.Request.Method != HEAD {
+				code = http.StatusPermanentRedirect
+			}
+			c.Redirect(code, u.String())
+			c.Abort()
+			return
+		}
+
+		header := c.Response.Header()
+		for k, v := range headers {
+			if v != "" {
+				header.Set(k, v)
+			}
+		}
+		if hsts != "" && https && !dbg {
+			header.Set(STRICT_TRANSPORT_SECURITY, hsts)
+		}
+		if conf.ContentSecurityPolicy == "" {
+			return
+		}
+		csp := conf.ContentSecurityPolicy
+		if nonce {
+			n := newCSPNonce()
+			c.Set(cspNonceKey, n)
+			csp = strings.Replace(csp, CSP_NONCE, n, -1)
+		}
+		header.Set(cspHeader, csp)
+	}
+}
+
+func secureHeader(value, def string) string {
+	switch value {
+	case "":
+		return def
+	case "-":
+		return ""
+	}
+	return value
+}
+
+//newCSPNonce returns 128 bits random in base64url, which is safe in any context of the templates
+func newCSPNonce() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+//CSPNonce returns the nonce of the Content-Security-Policy generated by Secure,
+//it is empty if the policy doesn't contain CSP_NONCE
+func (c *Context) CSPNonce() string {
+	n, _ := c.GetDef(cspNonceKey, "").(string)
+	return n
+}
@@ -0,0 +1,74 @@
+package goil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSecure(t *testing.T) {
+	html := NewHtmlTemp()
+	html.T.New("page").Parse(`<script nonce="{{cspNonce}}">var n = {{cspNonce}};</script>{{.}}`)
+	app := New(WithMode(PRD_MODE), WithHtmlRender(html))
+	app.Use(Secure(SecureConfig{
+		SSLRedirect:           true,
+		HSTSIncludeSubdomains: true,
+		FrameOptions:          "SAMEORIGIN",
+		ReferrerPolicy:        "-",
+		ContentSecurityPolicy: "script-src 'self' 'nonce-{nonce}'",
+	}))
+	app.GET("/page", func(c *Context) { c.Html("page", c.Query("q")) })
+	app.POST("/page", func(c *Context) {})
+
+	//redirect to https
+	if w := serve(app, GET, "/page?q=1"); w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "https://example.com/page?q=1" {
+		t.Errorf("GET http: %d %q", w.Code, w.Header().Get("Location"))
+	}
+	if w := serve(app, POST, "/page"); w.Code != http.StatusPermanentRedirect {
+		t.Errorf("POST http: code = %d; want %d", w.Code, http.StatusPermanentRedirect)
+	}
+
+	//behind the proxy
+	app.SetTrustedProxies("192.0.2.0/24")
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(GET, "/page?q=goilnonce", nil)
+	r.Header.Set(X_FORWARDED_FOR, "203.0.113.9")
+	r.Header.Set(X_FORWARDED_PROTO, "https")
+	app.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET https: code = %d; want %d", w.Code, http.StatusOK)
+	}
+	header := w.Header()
+	if v := header.Get(STRICT_TRANSPORT_SECURITY); v != "max-age=31536000; includeSubDomains" {
+		t.Errorf("HSTS = %q", v)
+	}
+	if header.Get(X_FRAME_OPTIONS) != "SAMEORIGIN" || header.Get(X_CONTENT_TYPE_OPTIONS) != "nosniff" {
+		t.Errorf("headers = %v", header)
+	}
+	if _, ok := header[REFERRER_POLICY]; ok {
+		t.Errorf("Referrer-Policy shouldn't be sent")
+	}
+	csp := header.Get(CONTENT_SECURITY_POLICY)
+	nonce := strings.TrimSuffix(strings.TrimPrefix(csp, "script-src 'self' 'nonce-"), "'")
+	if nonce == "" || nonce == csp {
+		t.Fatalf("CSP = %q", csp)
+	}
+	want := `<script nonce="` + nonce + `">var n = "` + nonce + `";</script>goilnonce`
+	if w.Body.String() != want {
+		t.Errorf("body = %q; want %q", w.Body.String(), want)
+	}
+	//a new nonce for every request
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, r)
+	if w.Header().Get(CONTENT_SECURITY_POLICY) == csp {
+		t.Errorf("the nonce is reused")
+	}
+
+	//neither redirect nor HSTS in DBG mode
+	app.conf.Mode = DBG_MODE
+	w = serve(app, GET, "/page")
+	if w.Code != http.StatusOK || w.Header().Get(STRICT_TRANSPORT_SECURITY) != "" {
+		t.Errorf("GET in DBG mode: %d %v", w.Code, w.Header())
+	}
+}
@@ -0,0 +1,199 @@
+package goil
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const DEFAULT_SHUTDOWN_TIMEOUT = 10 * time.Second
+
+var ErrServerRunning = errors.New("the server is already running.")
+var ErrServerNotRunning = errors.New("the server isn't running.")
+
+//ServerOptions configures the http server started by App.Server,
+//it can be loaded from the config files by ConfigLoader
+type ServerOptions struct {
+	//the tcp address to listen on, ignored if the Listener is set
+	Addr string `config:"addr"`
+	//serve on the listener instead of listening on Addr
+	Listener net.Listener `config:"-"`
+	//serve HTTPS if both the cert file and key file are set
+	CertFile string `config:"cert_file"`
+	KeyFile  string `config:"key_file"`
+
+	ReadTimeout       time.Duration `config:"read_timeout"`
+	ReadHeaderTimeout time.Duration `config:"read_header_timeout"`
+	WriteTimeout      time.Duration `config:"write_timeout"`
+	IdleTimeout       time.Duration `config:"idle_timeout"`
+	//the max time to wait for the in-flight requests when shutting down,
+	//DEFAULT_SHUTDOWN_TIMEOUT is used if it is zero
+	ShutdownTimeout time.Duration `config:"shutdown_timeout"`
+	//the signals which trigger the graceful shutdown,
+	//SIGINT and SIGTERM are used if it is nil,
+	//set it to an empty slice to disable the signal handling
+	Signals []os.Signal `config:"-"`
+}
+
+//OnStart registers a hook called before the server starts serving
+func (app *App) OnStart(hook func()) {
+	app.mu.Lock()
+	app.onStart = append(app.onStart, hook)
+	app.mu.Unlock()
+}
+
+//OnShutdown registers a hook called after the server has been shut down
+func (app *App) OnShutdown(hook func()) {
+	app.mu.Lock()
+	app.onShutdown = append(app.onShutdown, hook)
+	app.mu.Unlock()
+}
+
+//the running server and the result of its shutdown
+type serverState struct {
+	srv     *http.Server
+	timeout time.Duration
+	done    chan error
+}
+
+//Server starts the http server with the options and blocks until the server is closed.
+//the in-flight requests are drained when one of the signals is received or Shutdown is called,
+//it returns nil if the server is shut down gracefully
+func (app *App) Server(opts ServerOptions) (err error) {
+	state := &serverState{
+		srv: &http.Server{
+			Addr:              opts.Addr,
+			Handler:           app,
+			ReadTimeout:       opts.ReadTimeout,
+			ReadHeaderTimeout: opts.ReadHeaderTimeout,
+			WriteTimeout:      opts.WriteTimeout,
+			IdleTimeout:       opts.IdleTimeout,
+		},
+		timeout: opts.ShutdownTimeout,
+		done:    make(chan error, 1),
+	}
+	if state.timeout <= 0 {
+		state.timeout = DEFAULT_SHUTDOWN_TIMEOUT
+	}
+
+	app.mu.Lock()
+	if app.server != nil {
+		app.mu.Unlock()
+		return ErrServerRunning
+	}
+	app.server = state
+	onStart := app.onStart
+	app.mu.Unlock()
+
+	started := false
+	defer func() {
+		app.mu.Lock()
+		app.server = nil
+		onShutdown := app.onShutdown
+		app.mu.Unlock()
+		//nothing to shut down if listening fails
+		if !started {
+			return
+		}
+		for _, hook := range onShutdown {
+			hook()
+		}
+		//allow registering again after the server has been closed
+		app.guard.reset()
+	}()
+
+	ln := opts.Listener
+	if ln == nil {
+		addr := opts.Addr
+		if addr == "" {
+			addr = ":http"
+		}
+		ln, err = net.Listen("tcp", addr)
+		if err != nil {
+			return
+		}
+	}
+
+	//stop registering routes before serving
+	started = true
+	app.guard.run()
+	for _, hook := range onStart {
+		hook()
+	}
+
+	stop := app.handleSignals(state, opts.Signals)
+	defer stop()
+
+	l := app.conf.logger()
+	if opts.CertFile != "" && opts.KeyFile != "" {
+		l.Printf("[Goil] Listening and serving HTTPS on %s\n", ln.Addr())
+		err = state.srv.ServeTLS(ln, opts.CertFile, opts.KeyFile)
+	} else {
+		l.Printf("[Goil] Listening and serving HTTP on %s\n", ln.Addr())
+		err = state.srv.Serve(ln)
+	}
+	if err != http.ErrServerClosed {
+		return
+	}
+	//Serve returns immediately after Shutdown is called,
+	//wait for the in-flight requests
+	err = <-state.done
+	return
+}
+
+//Shutdown gracefully shuts down the server started by App.Server without interrupting
+//any active connections, it waits until all the in-flight requests are done or the ctx is done.
+//ErrServerNotRunning is returned if the server isn't running
+func (app *App) Shutdown(ctx context.Context) error {
+	app.mu.Lock()
+	state := app.server
+	app.mu.Unlock()
+	if state == nil {
+		return ErrServerNotRunning
+	}
+	return state.shutdown(ctx)
+}
+
+func (s *serverState) shutdown(ctx context.Context) error {
+	err := s.srv.Shutdown(ctx)
+	select {
+	case s.done <- err:
+	default:
+		//has been shut down by others
+	}
+	return err
+}
+
+func (app *App) handleSignals(state *serverState, signals []os.Signal) (stop func()) {
+	if signals == nil {
+		signals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+	if len(signals) == 0 {
+		return func() {}
+	}
+	ch := make(chan os.Signal, 1)
+	quit := make(chan struct{})
+	signal.Notify(ch, signals...)
+	go func() {
+		select {
+		case sig := <-ch:
+			l := app.conf.logger()
+			l.Printf("[Goil] receive signal %s, shutting down the server", sig)
+			ctx, cancel := context.WithTimeout(context.Background(), state.timeout)
+			defer cancel()
+			if err := state.shutdown(ctx); err != nil {
+				l.Errorf("when shutting down the server: %s", err)
+			}
+		case <-quit:
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		close(quit)
+	}
+}
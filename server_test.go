@@ -0,0 +1,94 @@
+package goil
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestServerShutdown(t *testing.T) {
+	app := New()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	app.GET("/slow", func(c *Context) {
+		close(started)
+		<-release
+		c.Text("done")
+	})
+
+	var onStart, onShutdown int
+	app.OnStart(func() { onStart++ })
+	app.OnShutdown(func() { onShutdown++ })
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- app.Server(ServerOptions{
+			Listener:    ln,
+			ReadTimeout: time.Second,
+			Signals:     []os.Signal{},
+		})
+	}()
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/slow")
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		byts, _ := ioutil.ReadAll(resp.Body)
+		body <- string(byts)
+	}()
+	<-started
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- app.Shutdown(context.Background())
+	}()
+	//the in-flight request should be drained
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	if b := <-body; b != "done" {
+		t.Errorf("body = %q; want %q", b, "done")
+	}
+	if err := <-shutdownErr; err != nil {
+		t.Errorf("Shutdown: %s", err)
+	}
+	if err := <-serverErr; err != nil {
+		t.Errorf("Server: %s", err)
+	}
+	if onStart != 1 || onShutdown != 1 {
+		t.Errorf("hooks called %d/%d times; want 1/1", onStart, onShutdown)
+	}
+
+	//the registration is allowed again after the server is closed
+	app.GET("/fast", func(c *Context) { c.Text("ok") })
+	if w := serve(app, GET, "/fast"); w.Code != http.StatusOK {
+		t.Errorf("GET /fast: code = %d; want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestServerNotStarted(t *testing.T) {
+	app := New()
+	onShutdown := 0
+	app.OnShutdown(func() { onShutdown++ })
+	if err := app.Shutdown(context.Background()); err != ErrServerNotRunning {
+		t.Errorf("Shutdown before Server = %v; want %v", err, ErrServerNotRunning)
+	}
+	if err := app.Server(ServerOptions{Addr: "127.0.0.1:-1", Signals: []os.Signal{}}); err == nil {
+		t.Fatal("Server should fail on the invalid address")
+	}
+	if onShutdown != 0 {
+		t.Errorf("the shutdown hooks shouldn't be called if the server never starts")
+	}
+}
@@ -0,0 +1,334 @@
+package goil
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"goil/helper/encoding/gob"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//the name of the session cookie by default
+const DEFAULT_SESSION_COOKIE = "goil_session"
+
+//the idle timeout of the sessions by default
+const DEFAULT_SESSION_IDLE = 30 * time.Minute
+
+//SessionsNotUsed panics when Context.Session is called without the Sessions middleware
+var SessionsNotUsed = errors.New("the Sessions middleware isn't used.")
+
+const (
+	//the key of the session stored in the context
+	sessionKey = "goil.session"
+	//the reserved keys in the values of the session
+	sessionCreated  = "_goil.created"
+	sessionAccessed = "_goil.accessed"
+	sessionFlash    = "_goil.flash."
+)
+
+//SessionConfig configures the middleware created by Sessions
+type SessionConfig struct {
+	//the secret signing the cookie, the cookie keys of the app are used if it is empty,
+	//see WithCookieKeys
+	Secret []byte
+	//NewMemorySessionStore is used if it is nil
+	Store SessionStore
+	//DEFAULT_SESSION_COOKIE is used if it is empty
+	CookieName string
+	//"/" is used if it is empty
+	CookiePath     string
+	CookieDomain   string
+	CookieSecure   bool
+	CookieSameSite http.SameSite
+	//the session expires if it isn't accessed in the IdleTimeout, DEFAULT_SESSION_IDLE is used
+	//if it is zero, and it never expires by idle if it is negative.
+	//the access time is saved lazily, at most once per quarter of the IdleTimeout unless modified
+	IdleTimeout time.Duration
+	//the session expires the MaxLifetime after created whatever accessed, zero means no limit
+	MaxLifetime time.Duration
+}
+
+//Session is the server-side session of the client, it is read by Context.Session.
+//the session is loaded from the store when it is read firstly, and saved only if it is modified.
+//the values are encoded by gob, so the custom types should be registered by gob.Register
+type Session struct {
+	conf *SessionConfig
+	c    *Context
+
+	id      string
+	values  map[interface{}]interface{}
+	flashes map[string]interface{}
+	//the id removed from the store when saving, for the regenerated or expired sessions
+	oldID     string
+	loaded    bool
+	isNew     bool
+	modified  bool
+	destroyed bool
+}
+
+//Sessions returns the middleware providing the sessions by Context.Session,
+//the cookie of the session is set before the response is written:
+//	app.Use(goil.Sessions(goil.SessionConfig{Secret: secret, Store: goil.NewRedisSessionStore(client, "session:")}))
+func Sessions(conf SessionConfig) HandlerFunc {
+	if conf.Store == nil {
+		conf.Store = NewMemorySessionStore()
+	}
+	if conf.CookieName == "" {
+		conf.CookieName = DEFAULT_SESSION_COOKIE
+	}
+	if conf.CookiePath == "" {
+		conf.CookiePath = "/"
+	}
+	if conf.CookieSameSite == 0 {
+		conf.CookieSameSite = http.SameSiteLaxMode
+	}
+	if conf.IdleTimeout == 0 {
+		conf.IdleTimeout = DEFAULT_SESSION_IDLE
+	}
+	return func(c *Context) {
+		s := &Session{conf: &conf, c: c}
+		c.Set(sessionKey, s)
+		c.BeforeWrite(s.save)
+	}
+}
+
+//Session returns the session of the request, it panics with SessionsNotUsed
+//if the Sessions middleware isn't used. the session isn't safe for concurrent use
+func (c *Context) Session() *Session {
+	s, ok := c.GetDef(sessionKey, nil).(*Session)
+	if !ok {
+		panic(SessionsNotUsed)
+	}
+	s.load()
+	return s
+}
+
+//ID returns the id of the session in the store
+func (s *Session) ID() string {
+	return s.id
+}
+
+//IsNew reports whether the session is created by the request
+func (s *Session) IsNew() bool {
+	return s.isNew
+}
+
+//Get returns the value of the key, the flash set by the previous request is returned as well
+func (s *Session) Get(key string) (val interface{}, exists bool) {
+	if val, exists = s.values[key]; exists {
+		return
+	}
+	val, exists = s.flashes[key]
+	return
+}
+
+//GetDef returns the value of the key, the def is returned if the key doesn't exist
+func (s *Session) GetDef(key string, def interface{}) interface{} {
+	if val, exists := s.Get(key); exists {
+		return val
+	}
+	return def
+}
+
+//Set sets the value of the key, the nil value deletes the key
+func (s *Session) Set(key string, value interface{}) {
+	if value == nil {
+		s.Delete(key)
+		return
+	}
+	s.values[key] = value
+	s.modified = true
+}
+
+//Delete deletes the key
+func (s *Session) Delete(key string) {
+	if _, exists := s.values[key]; exists {
+		delete(s.values, key)
+		s.modified = true
+	}
+}
+
+//Flash sets the value which can be read by Get only in the next request, such as the message after redirecting
+func (s *Session) Flash(key string, value interface{}) {
+	s.Set(sessionFlash+key, value)
+}
+
+//Regenerate changes the id of the session and keeps the values,
+//it should be called after logging in to prevent the session fixation
+func (s *Session) Regenerate() {
+	if !s.isNew && s.oldID == "" {
+		s.oldID = s.id
+	}
+	s.id = newSessionID()
+	s.modified = true
+}
+
+//Destroy removes the session from the store and the cookie
+func (s *Session) Destroy() {
+	s.values = make(map[interface{}]interface{})
+	s.flashes = nil
+	s.destroyed = true
+}
+
+//load loads the session by the cookie, a new session is created if it doesn't exist or expires
+func (s *Session) load() {
+	if s.loaded {
+		return
+	}
+	conf := s.conf
+	keys := s.keys()
+	s.loaded = true
+	now := time.Now()
+	if cookie, err := s.c.Request.Cookie(conf.CookieName); err == nil {
+		if id, ok := verifyValue(keys, conf.CookieName, cookie.Value); ok {
+			s.values = s.loadValues(id)
+			if s.values != nil && s.expired(now) {
+				s.values = nil
+				s.oldID = id
+			} else if s.values != nil {
+				s.id = id
+			}
+		}
+	}
+	if s.values == nil {
+		s.id = newSessionID()
+		s.values = map[interface{}]interface{}{
+			sessionCreated: now.Unix(),
+		}
+		s.isNew = true
+		return
+	}
+
+	//the flashes set by the previous request are removed
+	for k, v := range s.values {
+		if key, ok := k.(string); ok && strings.HasPrefix(key, sessionFlash) {
+			if s.flashes == nil {
+				s.flashes = make(map[string]interface{})
+			}
+			s.flashes[key[len(sessionFlash):]] = v
+			delete(s.values, k)
+			s.modified = true
+		}
+	}
+}
+
+func (s *Session) loadValues(id string) map[interface{}]interface{} {
+	data, err := s.conf.Store.Load(id)
+	if err != nil {
+		s.c.Logger().Errorf("when loading the session: %s", err)
+		return nil
+	}
+	if data == nil {
+		return nil
+	}
+	values, err := gob.DecodeMap(data)
+	if err != nil {
+		s.c.Logger().Errorf("when decoding the session: %s", err)
+		return nil
+	}
+	return values
+}
+
+func (s *Session) unix(key string) time.Time {
+	sec, _ := s.values[key].(int64)
+	return time.Unix(sec, 0)
+}
+
+func (s *Session) expired(now time.Time) bool {
+	conf := s.conf
+	if conf.MaxLifetime > 0 && now.Sub(s.unix(sessionCreated)) > conf.MaxLifetime {
+		return true
+	}
+	return conf.IdleTimeout > 0 && now.Sub(s.unix(sessionAccessed)) > conf.IdleTimeout
+}
+
+//save saves the session and sets the cookie if the session is modified or the access time should be refreshed
+func (s *Session) save() {
+	if !s.loaded {
+		return
+	}
+	conf := s.conf
+	c := s.c
+	if s.oldID != "" {
+		if err := conf.Store.Delete(s.oldID); err != nil {
+			c.Logger().Errorf("when deleting the session: %s", err)
+		}
+	}
+	if s.destroyed {
+		if !s.isNew {
+			if err := conf.Store.Delete(s.id); err != nil {
+				c.Logger().Errorf("when deleting the session: %s", err)
+			}
+		}
+		s.setCookie("", -1)
+		return
+	}
+
+	now := time.Now()
+	touch := !s.isNew && conf.IdleTimeout > 0 && now.Sub(s.unix(sessionAccessed)) >= conf.IdleTimeout/4
+	if !s.modified && !touch {
+		return
+	}
+	s.values[sessionAccessed] = now.Unix()
+	data, err := gob.EncodeMap(s.values)
+	if err != nil {
+		c.Logger().Errorf("when encoding the session: %s", err)
+		return
+	}
+
+	//the session expires by the idle or the lifetime firstly
+	ttl := conf.IdleTimeout
+	maxAge := 0
+	if conf.MaxLifetime > 0 {
+		left := s.unix(sessionCreated).Add(conf.MaxLifetime).Sub(now)
+		if ttl <= 0 || left < ttl {
+			ttl = left
+		}
+		//the created time is stored in seconds, so is the max age
+		maxAge = int(s.unix(sessionCreated).Add(conf.MaxLifetime).Unix() - now.Unix())
+		if maxAge <= 0 {
+			maxAge = -1
+		}
+	}
+	value, err := conf.Store.Save(s.id, data, ttl)
+	if err != nil {
+		c.Logger().Errorf("when saving the session: %s", err)
+		return
+	}
+	s.setCookie(signValue(s.keys(), conf.CookieName, value), maxAge)
+}
+
+//keys returns the keys signing the cookie, it panics with CookieKeysMissing if there is no key
+func (s *Session) keys() [][]byte {
+	if len(s.conf.Secret) > 0 {
+		return [][]byte{s.conf.Secret}
+	}
+	keys := s.c.config().cookieKeys()
+	if len(keys) == 0 {
+		panic(CookieKeysMissing)
+	}
+	return keys
+}
+
+func (s *Session) setCookie(value string, maxAge int) {
+	conf := s.conf
+	s.c.setCookie(conf.CookieName, value, CookieOptions{
+		Path:     conf.CookiePath,
+		Domain:   conf.CookieDomain,
+		MaxAge:   maxAge,
+		Secure:   conf.CookieSecure,
+		HttpOnly: true,
+		SameSite: conf.CookieSameSite,
+	})
+}
+
+//newSessionID returns 256 bits random in base64url
+func newSessionID() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
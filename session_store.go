@@ -0,0 +1,160 @@
+package goil
+
+import (
+	"encoding/base64"
+	"errors"
+	"goil/helper/redis"
+	"sync"
+	"time"
+)
+
+//SessionTooLarge is returned by the CookieSessionStore if the session doesn't fit in the cookie
+var SessionTooLarge = errors.New("the session is too large for the cookie.")
+
+//the max bytes of the session in the cookie
+const maxCookieSession = 4000
+
+//SessionStore stores the encoded sessions for the Sessions middleware
+type SessionStore interface {
+	//Load returns the session of the id in the cookie, the data is nil if it doesn't exist
+	Load(id string) (data []byte, err error)
+	//Save saves the session, which expires after the ttl if it is positive,
+	//the value returned is set in the cookie as the id
+	Save(id string, data []byte, ttl time.Duration) (value string, err error)
+	//Delete removes the session of the id
+	Delete(id string) error
+}
+
+//the interval of removing the expired sessions of the MemorySessionStore
+const memorySessionSweep = time.Minute
+
+//MemorySessionStore stores the sessions in the memory, which are lost when the process exits
+type MemorySessionStore struct {
+	mu        sync.Mutex
+	sessions  map[string]memorySession
+	lastSweep time.Time
+}
+
+type memorySession struct {
+	data   []byte
+	expire time.Time
+}
+
+//NewMemorySessionStore creates the MemorySessionStore
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions: make(map[string]memorySession),
+	}
+}
+
+func (s *MemorySessionStore) Load(id string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+	if !session.expire.IsZero() && time.Now().After(session.expire) {
+		delete(s.sessions, id)
+		return nil, nil
+	}
+	return session.data, nil
+}
+
+func (s *MemorySessionStore) Save(id string, data []byte, ttl time.Duration) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.Sub(s.lastSweep) >= memorySessionSweep {
+		for k, session := range s.sessions {
+			if !session.expire.IsZero() && now.After(session.expire) {
+				delete(s.sessions, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	session := memorySession{data: data}
+	if ttl > 0 {
+		session.expire = now.Add(ttl)
+	}
+	s.sessions[id] = session
+	return id, nil
+}
+
+func (s *MemorySessionStore) Delete(id string) error {
+	s.mu.Lock()
+	delete(s.sessions, id)
+	s.mu.Unlock()
+	return nil
+}
+
+//CookieSessionStore stores the whole session in the cookie, which is signed but readable by the client.
+//the expiry is checked by the time saved in the session
+type CookieSessionStore struct{}
+
+//NewCookieSessionStore creates the CookieSessionStore
+func NewCookieSessionStore() *CookieSessionStore {
+	return &CookieSessionStore{}
+}
+
+func (s *CookieSessionStore) Load(id string) ([]byte, error) {
+	data, err := base64.RawURLEncoding.DecodeString(id)
+	if err != nil {
+		return nil, nil
+	}
+	return data, nil
+}
+
+func (s *CookieSessionStore) Save(id string, data []byte, ttl time.Duration) (string, error) {
+	value := base64.RawURLEncoding.EncodeToString(data)
+	if len(value) > maxCookieSession {
+		return "", SessionTooLarge
+	}
+	return value, nil
+}
+
+func (s *CookieSessionStore) Delete(id string) error {
+	return nil
+}
+
+//RedisSessionStore stores the sessions on redis, which are shared by the processes
+type RedisSessionStore struct {
+	client *redis.RedisClient
+	prefix string
+}
+
+//NewRedisSessionStore creates the RedisSessionStore, the prefix is prepended to the ids as the keys
+func NewRedisSessionStore(client *redis.RedisClient, prefix string) *RedisSessionStore {
+	return &RedisSessionStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+func (s *RedisSessionStore) Load(id string) ([]byte, error) {
+	reply, err := s.client.GetObj(s.prefix + id)
+	if err != nil || reply == nil {
+		return nil, err
+	}
+	data, ok := reply.([]byte)
+	if !ok {
+		return nil, errors.New("invalid session on redis")
+	}
+	return data, nil
+}
+
+func (s *RedisSessionStore) Save(id string, data []byte, ttl time.Duration) (string, error) {
+	var err error
+	if ttl > 0 {
+		//round up to keep the session until it expires
+		_, err = s.client.SetWithExpire(s.prefix+id, data, int64((ttl+time.Second-1)/time.Second))
+	} else {
+		_, err = s.client.Set(s.prefix+id, data)
+	}
+	return id, err
+}
+
+func (s *RedisSessionStore) Delete(id string) error {
+	_, err := s.client.Del(s.prefix + id)
+	return err
+}
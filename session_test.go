@@ -0,0 +1,151 @@
+package goil
+
+import (
+	"goil/helper/encoding/gob"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessions(t *testing.T) {
+	for _, store := range []SessionStore{NewMemorySessionStore(), NewCookieSessionStore()} {
+		testSessions(t, store)
+	}
+}
+
+func testSessions(t *testing.T, store SessionStore) {
+	app := New()
+	app.Use(Sessions(SessionConfig{Secret: []byte("secret"), Store: store}))
+	app.GET("/none", func(c *Context) { c.Text("none") })
+	app.GET("/get", func(c *Context) {
+		s := c.Session()
+		c.Text(strings.Join([]string{
+			s.GetDef("user", "").(string),
+			s.GetDef("msg", "").(string),
+		}, ","))
+	})
+	app.POST("/login", func(c *Context) {
+		s := c.Session()
+		s.Regenerate()
+		s.Set("user", c.Query("user"))
+		s.Flash("msg", "welcome")
+		c.Text(s.ID())
+	})
+	app.POST("/logout", func(c *Context) { c.Session().Destroy() })
+
+	var cookie *http.Cookie
+	request := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, nil)
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+		app.ServeHTTP(w, r)
+		for _, c := range w.Result().Cookies() {
+			if c.Name == DEFAULT_SESSION_COOKIE {
+				cookie = c
+			}
+		}
+		return w
+	}
+
+	//not modified
+	if w := request(GET, "/get"); w.Header().Get("Set-Cookie") != "" {
+		t.Errorf("%T: the new session shouldn't be saved until modified", store)
+	}
+	if w := request(GET, "/none"); w.Header().Get("Set-Cookie") != "" {
+		t.Errorf("%T: the session shouldn't be saved if not used", store)
+	}
+
+	request(POST, "/login?user=Jim")
+	if cookie == nil || !cookie.HttpOnly {
+		t.Fatalf("%T: the cookie = %v", store, cookie)
+	}
+	//the flash is read only once
+	if w := request(GET, "/get"); w.Body.String() != "Jim,welcome" {
+		t.Errorf("%T: GET /get = %q", store, w.Body.String())
+	}
+	if w := request(GET, "/get"); w.Body.String() != "Jim," {
+		t.Errorf("%T: GET /get again = %q", store, w.Body.String())
+	}
+
+	//the forged cookie
+	saved := cookie
+	cookie = &http.Cookie{Name: DEFAULT_SESSION_COOKIE, Value: "x" + saved.Value}
+	if w := request(GET, "/get"); w.Body.String() != "," {
+		t.Errorf("%T: the forged cookie is accepted: %q", store, w.Body.String())
+	}
+	cookie = saved
+
+	request(POST, "/logout")
+	if cookie.MaxAge >= 0 {
+		t.Errorf("%T: the cookie isn't removed by Destroy", store)
+	}
+	cookie = saved
+	if _, ok := store.(*MemorySessionStore); ok {
+		if w := request(GET, "/get"); w.Body.String() != "," {
+			t.Errorf("%T: the session isn't destroyed: %q", store, w.Body.String())
+		}
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	store := NewMemorySessionStore()
+	conf := SessionConfig{Secret: []byte("secret"), Store: store, IdleTimeout: time.Hour, MaxLifetime: 2 * time.Hour}
+	app := New()
+	app.Use(Sessions(conf))
+	app.GET("/", func(c *Context) {
+		if c.Session().IsNew() {
+			c.Session().Set("n", 1)
+		}
+		c.Text(c.Session().ID())
+	})
+	w := serve(app, GET, "/")
+	id := w.Body.String()
+	cookie := w.Result().Cookies()[0]
+	if cookie.MaxAge != 7200 {
+		t.Errorf("MaxAge = %d; want 7200", cookie.MaxAge)
+	}
+
+	//rewrite the access and created time
+	shift := func(key string, d time.Duration) {
+		data, _ := store.Load(id)
+		s := &Session{}
+		s.values, _ = gob.DecodeMap(data)
+		s.values[key] = s.unix(key).Add(-d).Unix()
+		data, _ = gob.EncodeMap(s.values)
+		store.Save(id, data, 0)
+	}
+	get := func() string {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(GET, "/", nil)
+		r.AddCookie(cookie)
+		app.ServeHTTP(w, r)
+		return w.Body.String()
+	}
+	shift(sessionAccessed, 50*time.Minute)
+	if get() != id {
+		t.Errorf("the session expires before the idle timeout")
+	}
+	//touched
+	shift(sessionAccessed, 50*time.Minute)
+	if get() != id {
+		t.Errorf("the access time isn't refreshed")
+	}
+	shift(sessionAccessed, 61*time.Minute)
+	if get() == id {
+		t.Errorf("the session doesn't expire by the idle timeout")
+	}
+	if data, _ := store.Load(id); data != nil {
+		t.Errorf("the expired session isn't deleted")
+	}
+
+	w = serve(app, GET, "/")
+	id, cookie = w.Body.String(), w.Result().Cookies()[0]
+	shift(sessionCreated, 121*time.Minute)
+	if get() == id {
+		t.Errorf("the session doesn't expire by the max lifetime")
+	}
+}
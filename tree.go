@@ -6,6 +6,7 @@ package goil
 import (
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 const (
@@ -51,6 +52,8 @@ type (
 		next         *node        //右邻兄弟节点
 		pre          *node        //左邻兄弟节点
 		handlerChain HandlerChain //作用于该节点的中间件
+		constraint   *constraint  //参数节点的约束
+		route        *Route       //节点对应的路由信息
 	}
 )
 
@@ -79,8 +82,9 @@ root: 路由树根节点
 path: 目标path
 handler: 对应的处理函数
 chain: 注册节点时传入的中间件
+conf: 查找参数约束的配置，nil 时使用默认配置
 */
-func (root *node) addNode(path string, chain HandlerChain) *node {
+func (root *node) addNode(path string, chain HandlerChain, conf *Config) *node {
 	if path == "" || path[0] != '/' {
 		panic("url must start with '/'")
 	}
@@ -118,6 +122,7 @@ func (root *node) addNode(path string, chain HandlerChain) *node {
 					head:         parent.head,
 					tail:         parent.tail,
 					handlerChain: parent.handlerChain,
+					route:        parent.route,
 				}
 				for ch := child.head; ch != nil; ch = ch.next {
 					if child.maxParams < ch.maxParams {
@@ -126,6 +131,7 @@ func (root *node) addNode(path string, chain HandlerChain) *node {
 				}
 
 				parent.handlerChain = nil
+				parent.route = nil
 				parent.pattern = pPattern[:preIdx]
 				parent.head = child
 				parent.tail = child
@@ -153,6 +159,13 @@ func (root *node) addNode(path string, chain HandlerChain) *node {
 				cp := child.pattern[0]
 
 				if cp == ':' || cp == '*' {
+					//同一位置允许存在约束不同的参数节点，如 :id<int> 和 :name
+					if cp == ':' && cc == ':' {
+						seg := wildSegment(cPattern)
+						if seg != child.pattern && constraintSpec(seg) != constraintSpec(child.pattern) {
+							continue
+						}
+					}
 					//there has been wild node
 					if cp == cc { //判断是否相同wild节点，如果是,continue
 						i := len(child.pattern)
@@ -194,7 +207,7 @@ func (root *node) addNode(path string, chain HandlerChain) *node {
 				}
 			}
 			//已经没有公共前缀了，添加新的子节点
-			return parent.appendChild(paramNum, cPattern, path, chain)
+			return parent.appendChild(paramNum, cPattern, path, chain, conf)
 
 		}
 	} else { //insert root "/"
@@ -209,7 +222,127 @@ func (root *node) addNode(path string, chain HandlerChain) *node {
 	panic("fatal error when add route node '" + path + "'")
 }
 
-func (n *node) appendChild(numParams uint8, pattern, path string, chain HandlerChain) (child *node) {
+func newRoot() *node {
+	return &node{
+		pattern: "/",
+		typ:     static,
+	}
+}
+
+/**
+深拷贝路由树，用于写时复制
+*/
+func (n *node) clone() *node {
+	cp := *n
+	cp.head, cp.tail, cp.pre, cp.next = nil, nil, nil, nil
+	for ch := n.head; ch != nil; ch = ch.next {
+		c := ch.clone()
+		if cp.tail == nil {
+			cp.head = c
+		} else {
+			cp.tail.next = c
+			c.pre = cp.tail
+		}
+		cp.tail = c
+	}
+	return &cp
+}
+
+/**
+删除路由节点
+参数:
+path: 注册时的路由模式
+返回:
+是否找到并删除
+*/
+func (root *node) removeNode(path string) bool {
+	if root == nil || root.pattern != "/" || path == "" || path[0] != '/' {
+		return false
+	}
+	//查找路由对应的节点，并记录经过的节点
+	parents := make([]*node, 0, 8)
+	n := root
+	rest := path
+	for {
+		if n.typ == static {
+			if !strings.HasPrefix(rest, n.pattern) {
+				return false
+			}
+		} else if wildSegment(rest) != n.pattern {
+			return false
+		}
+		rest = rest[len(n.pattern):]
+		if rest == "" {
+			break
+		}
+		var next *node
+		for ch := n.head; ch != nil; ch = ch.next {
+			if ch.typ == static {
+				if ch.pattern[0] == rest[0] {
+					next = ch
+					break
+				}
+			} else if ch.pattern == wildSegment(rest) {
+				next = ch
+				break
+			}
+		}
+		if next == nil {
+			return false
+		}
+		parents = append(parents, n)
+		n = next
+	}
+	if n.handlerChain == nil {
+		return false
+	}
+	n.handlerChain = nil
+	n.route = nil
+
+	//删除无用的节点
+	for n != root && n.handlerChain == nil && n.head == nil {
+		parent := parents[len(parents)-1]
+		parents = parents[:len(parents)-1]
+		parent.unlink(n)
+		n = parent
+	}
+	//重新压缩只有一个静态子节点的静态节点，根节点保持为 '/'
+	if n != root {
+		n.compress()
+	}
+	for _, p := range parents {
+		if p != root {
+			p.compress()
+		}
+	}
+	return true
+}
+
+func (n *node) unlink(child *node) {
+	if child.pre == nil {
+		n.head = child.next
+	} else {
+		child.pre.next = child.next
+	}
+	if child.next == nil {
+		n.tail = child.pre
+	} else {
+		child.next.pre = child.pre
+	}
+	child.pre, child.next = nil, nil
+}
+
+func (n *node) compress() {
+	for n.typ == static && n.handlerChain == nil && n.head != nil && n.head == n.tail && n.head.typ == static {
+		child := n.head
+		n.pattern += child.pattern
+		n.handlerChain = child.handlerChain
+		n.route = child.route
+		n.head, n.tail = child.head, child.tail
+	}
+}
+
+func (n *node) appendChild(numParams uint8, pattern, path string, chain HandlerChain, conf *Config) (child *node) {
 	pl := len(pattern)
 	if pl == 0 {
 		return nil
@@ -251,6 +384,19 @@ func (n *node) appendChild(numParams uint8, pattern, path string, chain HandlerC
 			buf = append(buf, pattern[idx])
 			idx++
 			for idx < pl && pattern[idx] != '/' {
+				//the constraint of the param
+				if pattern[idx] == '<' {
+					end := constraintEnd(pattern, idx)
+					if end < 0 {
+						panic("invalid constraint '" + pattern[idx:] + "' in path '" + path + "'")
+					}
+					buf = append(buf, pattern[idx:end]...)
+					idx = end
+					if idx < pl && pattern[idx] != '/' {
+						panic("the constraint must be at the end of the segment in path '" + path + "'")
+					}
+					break
+				}
 				//repeat wild char,return error
 				if pattern[idx] == ':' || pattern[idx] == '*' {
 					panic("only one wildcard per path segment is allowed, has:'" + pattern[i:] + "' in path '" + path + "'")
@@ -267,15 +413,21 @@ func (n *node) appendChild(numParams uint8, pattern, path string, chain HandlerC
 				panic("wildcard '*' are only allowed at the end of the path in path '" + path + "'")
 			}
 			typ := uint8(0)
+			var cons *constraint
 			if pattern[i] == ':' {
 				typ = param
+				cons = newConstraint(conf, constraintSpec(string(buf)))
 			} else {
 				typ = catchAll
+				if constraintSpec(string(buf)) != "" {
+					panic("the catch-all param can't have constraint in path '" + path + "'")
+				}
 			}
 			child = &node{
-				pattern:   string(buf),
-				maxParams: numParams,
-				typ:       typ,
+				pattern:    string(buf),
+				maxParams:  numParams,
+				typ:        typ,
+				constraint: cons,
 			}
 			if parent.tail == nil {
 				parent.tail = child
@@ -322,6 +474,65 @@ func (n *node) appendChild(numParams uint8, pattern, path string, chain HandlerC
 	return
 }
 
+//wildSegment returns the wildcard segment at the beginning of the pattern, such as ':id<int>'
+func wildSegment(pattern string) string {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '/':
+			return pattern[:i]
+		case '<':
+			if end := constraintEnd(pattern, i); end > 0 {
+				return pattern[:end]
+			}
+			return pattern
+		}
+	}
+	return pattern
+}
+
+//paramName returns the name of the wildcard segment, ':id<int>' => 'id'
+func paramName(seg string) string {
+	if i := strings.IndexByte(seg, '<'); i > 0 {
+		return seg[1:i]
+	}
+	return seg[1:]
+}
+
+//constraintSpec returns the constraint of the wildcard segment, ':id<int>' => 'int'
+func constraintSpec(seg string) string {
+	i := strings.IndexByte(seg, '<')
+	if i < 0 {
+		return ""
+	}
+	return seg[i+1 : len(seg)-1]
+}
+
+//constraintEnd returns the index after the '>' which closes the constraint starting at start,
+//the parentheses in the constraint must be balanced, and '/' is not allowed
+func constraintEnd(pattern string, start int) int {
+	depth := 0
+	for i := start + 1; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '/':
+			return -1
+		case '>':
+			if depth == 0 {
+				if i == start+1 {
+					return -1
+				}
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
 func getPrefix(p string, c string) int {
 	pl := len(p)
 	cl := len(c)
@@ -363,7 +574,21 @@ func getParamNum(url string) uint8 {
 	return uint8(paramNum)
 }
 
-func (root *node) routerMapping(path string) (chain HandlerChain, params Params, tsr bool) {
+func (root *node) routerMapping(path string) (chain HandlerChain, params Params, tsr bool, route *Route) {
+	//such as the empty path of CONNECT
+	if len(path) == 0 || path[0] != '/' {
+		return
+	}
+	return root.match(path, nil)
+}
+
+/**
+从当前节点开始匹配路径，有约束的参数节点匹配失败时回溯到无约束的兄弟节点
+参数:
+path: 从当前节点开始的路径
+typed: 当前节点是参数节点时，通过约束检查的参数值
+*/
+func (root *node) match(path string, typed interface{}) (chain HandlerChain, params Params, tsr bool, route *Route) {
 	idx := 0
 	pl := len(path)
 
@@ -395,7 +620,7 @@ lookup:
 				params = make(Params, 0, curNode.maxParams)
 			}
 
-			paramKey := curNode.pattern[1:]
+			paramKey := paramName(curNode.pattern)
 
 			//匿名参数，添加默认参数名
 			if paramKey == "" {
@@ -405,9 +630,10 @@ lookup:
 			for idx < pl && path[idx] != '/' {
 				idx++
 			}
-			params.set(paramKey, path[i:idx])
+			params.setTyped(paramKey, path[i:idx], typed)
+			typed = nil
 			if idx >= pl {
-				chain = curNode.getHandlerChain()
+				chain, route = curNode.getHandlerChain(), curNode.route
 				if chain == nil {
 					for ch := curNode.head; ch != nil; ch = ch.next {
 						if ch.pattern == "/" {
@@ -434,7 +660,7 @@ lookup:
 			if lv >= lg && path[idx:idx+lg] == curNode.pattern {
 				idx += lg
 				if lv == lg {
-					chain = curNode.getHandlerChain()
+					chain, route = curNode.getHandlerChain(), curNode.route
 					if chain == nil {
 						for ch := curNode.head; ch != nil; ch = ch.next {
 							if ch.pattern == "/" {
@@ -445,6 +671,10 @@ lookup:
 								break lookup
 							}
 						}
+						//one '/' extra,suggest to redirect
+						if curNode.pattern == "/" && preNode != nil && preNode.handlerChain != nil {
+							tsr = true
+						}
 					}
 					//ret()
 					return
@@ -457,13 +687,28 @@ lookup:
 		}
 
 		preNode = curNode
+		//没有约束的参数节点，在有约束的参数节点都不匹配时使用
+		var paramNode *node = nil
 		for ch := curNode.head; ch != nil; ch = ch.next {
 			switch ch.typ {
 			case param:
-				//strict conflict check mode
-
-				curNode = ch
-				continue lookup
+				if ch.constraint == nil {
+					paramNode = ch
+					continue
+				}
+				i := idx
+				for i < pl && path[i] != '/' {
+					i++
+				}
+				if value, ok := ch.constraint.check(path[idx:i]); ok {
+					//the subtree of the constrained param may not match the rest of the path,
+					//so match it alone and try the siblings if it fails
+					c, p, t, r := ch.match(path[idx:], value)
+					if c != nil {
+						return c, append(params, p...), t, r
+					}
+					tsr = tsr || t
+				}
 
 			case catchAll:
 
@@ -479,17 +724,21 @@ lookup:
 				}
 			}
 		}
+		if paramNode != nil {
+			curNode = paramNode
+			continue lookup
+		}
 
 		break
 	}
 
 	//catchAllNode is not nil,use it
 	if catchAllNode != nil {
-		chain = catchAllNode.getHandlerChain()
+		chain, route = catchAllNode.getHandlerChain(), catchAllNode.route
 		if params == nil {
 			params = make(Params, catchAllNode.maxParams)
 		}
-		paramKey := catchAllNode.pattern[1:]
+		paramKey := paramName(catchAllNode.pattern)
 
 		if paramKey == "" {
 			paramKey = "param" + strconv.Itoa(len(params))
@@ -500,6 +749,7 @@ lookup:
 	}
 	chain = nil
 	params = nil
+	route = nil
 	//one '/' extra,suggest to redirect
 	if (idx == pl-1 && path[idx] == '/' && preNode.handlerChain != nil) || (path[pl-1] == '/' && path[idx:pl-1] == curNode.pattern && curNode.handlerChain != nil) {
 		tsr = true
@@ -513,6 +763,83 @@ func (n *node) getHandlerChain() HandlerChain {
 	return n.handlerChain
 }
 
+/**
+大小写不敏感地查找路由，用于修正请求路径
+参数:
+path: 经过CleanPath处理的请求路径
+fixTrailingSlash: 是否同时修正多余或缺少的 '/'
+返回:
+修正后的路径以及是否找到
+*/
+func (root *node) findCaseInsensitivePath(path string, fixTrailingSlash bool) (string, bool) {
+	buf := make([]byte, 0, len(path)+1)
+	buf, found := root.findCaseInsensitivePathRec(path, buf, fixTrailingSlash)
+	return string(buf), found
+}
+
+func (n *node) findCaseInsensitivePathRec(path string, buf []byte, fixTrailingSlash bool) ([]byte, bool) {
+	switch n.typ {
+	case catchAll:
+		if n.handlerChain == nil {
+			return buf, false
+		}
+		return append(buf, path...), true
+
+	case param:
+		end := 0
+		for end < len(path) && path[end] != '/' {
+			end++
+		}
+		if n.constraint != nil {
+			if _, ok := n.constraint.check(path[:end]); !ok {
+				return buf, false
+			}
+		}
+		//the param value keeps its case
+		buf = append(buf, path[:end]...)
+		path = path[end:]
+
+	default:
+		lp := len(n.pattern)
+		if len(path) < lp || !strings.EqualFold(path[:lp], n.pattern) {
+			//less one '/'
+			if fixTrailingSlash && n.handlerChain != nil && strings.EqualFold(path+"/", n.pattern) {
+				return append(buf, n.pattern...), true
+			}
+			return buf, false
+		}
+		buf = append(buf, n.pattern...)
+		path = path[lp:]
+	}
+
+	if path == "" {
+		if n.handlerChain != nil {
+			return buf, true
+		}
+		//less one '/'
+		if fixTrailingSlash {
+			for ch := n.head; ch != nil; ch = ch.next {
+				if ch.pattern == "/" && ch.handlerChain != nil {
+					return append(buf, '/'), true
+				}
+			}
+		}
+		return buf, false
+	}
+
+	for ch := n.head; ch != nil; ch = ch.next {
+		if ret, found := ch.findCaseInsensitivePathRec(path, buf, fixTrailingSlash); found {
+			return ret, true
+		}
+	}
+
+	//one '/' extra
+	if fixTrailingSlash && path == "/" && n.handlerChain != nil {
+		return buf, true
+	}
+	return buf, false
+}
+
 /**
 打印Tree，深度优先遍历
 */
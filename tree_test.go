@@ -1 +1,214 @@
 package goil
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func fakeChain(c *Context) {}
+
+func buildTree(paths ...string) *node {
+	root := &node{
+		pattern: "/",
+		typ:     static,
+	}
+	for _, p := range paths {
+		root.addNode(p, HandlerChain{fakeChain}, nil)
+	}
+	return root
+}
+
+func TestFindCaseInsensitivePath(t *testing.T) {
+	root := buildTree(
+		"/users",
+		"/users/:id",
+		"/users/:id/posts/",
+		"/static/*filepath",
+		"/Doc/go_faq.html",
+	)
+
+	cases := []struct {
+		in    string
+		fix   bool
+		out   string
+		found bool
+	}{
+		{"/USERS", false, "/users", true},
+		{"/Users/Jim", false, "/users/Jim", true},
+		{"/USERS/Jim/POSTS/", false, "/users/Jim/posts/", true},
+		{"/STATIC/Css/App.css", false, "/static/Css/App.css", true},
+		{"/doc/GO_FAQ.html", false, "/Doc/go_faq.html", true},
+		{"/USERS/", true, "/users", true},
+		{"/USERS/", false, "", false},
+		{"/users/Jim/POSTS", true, "/users/Jim/posts/", true},
+		{"/users/Jim/POSTS", false, "", false},
+		{"/nope", true, "", false},
+	}
+	for _, cs := range cases {
+		out, found := root.findCaseInsensitivePath(cs.in, cs.fix)
+		if found != cs.found || (found && out != cs.out) {
+			t.Errorf("findCaseInsensitivePath(%q, %v) = %q, %v; want %q, %v", cs.in, cs.fix, out, found, cs.out, cs.found)
+		}
+	}
+}
+
+func TestRouterMappingTSR(t *testing.T) {
+	root := buildTree(
+		"/users",
+		"/users/:id/",
+		"/files/:dir/*filepath",
+	)
+
+	cases := []struct {
+		in  string
+		tsr bool
+	}{
+		{"/users/", true},
+		{"/users/Jim", true},
+		{"/files/img", true},
+		{"/nope", false},
+	}
+	for _, cs := range cases {
+		chain, _, tsr, _ := root.routerMapping(cs.in)
+		if chain != nil {
+			t.Errorf("routerMapping(%q) should not match", cs.in)
+		}
+		if tsr != cs.tsr {
+			t.Errorf("routerMapping(%q) tsr = %v; want %v", cs.in, tsr, cs.tsr)
+		}
+	}
+}
+
+func TestConstrainedParams(t *testing.T) {
+	//the convert is registered for the tree only, so it doesn't leak into other tests
+	conf := &Config{}
+	WithConvert("hex", func(value string, dTyp reflect.Type) (interface{}, error) {
+		return strconv.ParseUint(value, 16, 64)
+	})(conf)
+
+	root := &node{
+		pattern: "/",
+		typ:     static,
+	}
+	var matched string
+	add := func(path string) {
+		root.addNode(path, HandlerChain{func(c *Context) { matched = path }}, conf)
+	}
+	add("/users/:id<int>")
+	add("/users/:name")
+	add("/users/:id<int>/posts")
+	add(`/files/:name<regex([a-z]+\.png)>`)
+	add("/v:version<uint>/info")
+	add("/colors/:rgb<hex>")
+	add("/members/:id<int>/posts")
+	add("/members/:name/profile")
+
+	cases := []struct {
+		path  string
+		route string
+		key   string
+		typed interface{}
+	}{
+		{"/users/10", "/users/:id<int>", "id", int64(10)},
+		{"/users/Jim", "/users/:name", "name", "Jim"},
+		{"/users/10/posts", "/users/:id<int>/posts", "id", int64(10)},
+		{"/files/a.png", `/files/:name<regex([a-z]+\.png)>`, "name", "a.png"},
+		{"/files/A.png", "", "", nil},
+		{"/files/a.pngx", "", "", nil},
+		{"/v2/info", "/v:version<uint>/info", "version", uint64(2)},
+		{"/v-2/info", "", "", nil},
+		{"/colors/ff", "/colors/:rgb<hex>", "rgb", uint64(255)},
+		{"/colors/zz", "", "", nil},
+		//falls back to the unconstrained param when the subtree of the constrained one doesn't match
+		{"/members/10/profile", "/members/:name/profile", "name", "10"},
+		{"/members/10/posts", "/members/:id<int>/posts", "id", int64(10)},
+		{"/members/Jim/posts", "", "", nil},
+	}
+	for _, cs := range cases {
+		chain, params, _, _ := root.routerMapping(cs.path)
+		if cs.route == "" {
+			if chain != nil {
+				t.Errorf("%s should not match", cs.path)
+			}
+			continue
+		}
+		if chain == nil {
+			t.Errorf("%s should match %s", cs.path, cs.route)
+			continue
+		}
+		if chain[0](nil); matched != cs.route {
+			t.Errorf("%s matched %s; want %s", cs.path, matched, cs.route)
+		}
+		if typed, _ := params.getTyped(cs.key); typed != cs.typed {
+			t.Errorf("%s: param %s = %#v; want %#v", cs.path, cs.key, typed, cs.typed)
+		}
+	}
+
+	conflicts := []string{"/users/:uid", "/users/:uid<int>", "/colors/:rgb<nope>", "/files/*name<int>"}
+	for _, path := range conflicts {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("adding %s should panic", path)
+				}
+			}()
+			root.addNode(path, HandlerChain{fakeChain}, conf)
+		}()
+	}
+}
+
+func TestRemoveNode(t *testing.T) {
+	root := buildTree(
+		"/users",
+		"/users/:id",
+		"/users/:id/posts",
+		"/uploads/*filepath",
+	)
+	orig := root.clone()
+
+	if root.removeNode("/users/:name") {
+		t.Errorf("removeNode should not remove the route with the other param name")
+	}
+	if root.removeNode("/use") {
+		t.Errorf("removeNode should not remove the route which isn't registered")
+	}
+	for _, p := range []string{"/users/:id", "/uploads/*filepath"} {
+		if !root.removeNode(p) {
+			t.Fatalf("removeNode(%q) = false", p)
+		}
+	}
+	if root.removeNode("/users/:id") {
+		t.Errorf("removeNode should not remove the route twice")
+	}
+
+	cases := []struct {
+		path  string
+		found bool
+	}{
+		{"/users", true},
+		{"/users/Jim", false},
+		{"/users/Jim/posts", true},
+		{"/uploads/a.png", false},
+	}
+	for _, cs := range cases {
+		chain, _, _, _ := root.routerMapping(cs.path)
+		if (chain != nil) != cs.found {
+			t.Errorf("routerMapping(%q) found = %v; want %v", cs.path, chain != nil, cs.found)
+		}
+		//the clone isn't affected by the removing
+		if chain, _, _, _ := orig.routerMapping(cs.path); chain == nil {
+			t.Errorf("the clone should match %q", cs.path)
+		}
+	}
+
+	//the static nodes are merged after removing
+	root.removeNode("/users/:id/posts")
+	if root.head == nil || root.head != root.tail || root.head.pattern != "users" || root.head.head != nil {
+		t.Errorf("the tree isn't compressed after removing")
+	}
+	root.addNode("/users/:id", HandlerChain{fakeChain}, nil)
+	if chain, _, _, _ := root.routerMapping("/users/Jim"); chain == nil {
+		t.Errorf("the route should be added again after removing")
+	}
+}
@@ -268,19 +268,20 @@ var validateFunc = map[string]ＶalidateFunc{
 	},
 }
 
+//RegisterValidator registers the validator shared by all the apps, see WithValidator
 func RegisterValidator(name string, fun ＶalidateFunc) {
-	guard.execSafely(func() {
-		validateFunc[name] = fun
-	})
+	defaultsMu.Lock()
+	validateFunc[name] = fun
+	defaultsMu.Unlock()
 }
 
-func validateField(tag string, val reflect.Value, rTyp reflect.StructField) error {
+func validateField(conf *Config, tag string, val reflect.Value, rTyp reflect.StructField) error {
 	keys, params, err := parseTag(tag)
 	if err != nil {
 		panic(err)
 	}
 	for i := range keys {
-		if validator, exists := validateFunc[keys[i]]; exists {
+		if validator, exists := conf.validator(keys[i]); exists {
 			f := ValidatedField{
 				Value:  val,
 				Type:   rTyp.Type,
@@ -298,7 +299,7 @@ func validateField(tag string, val reflect.Value, rTyp reflect.StructField) erro
 	return nil
 }
 
-func validate(iface interface{}) error {
+func validate(conf *Config, iface interface{}) error {
 	if iface == nil {
 		return errors.New("nil pointer for validate")
 	}
@@ -318,14 +319,14 @@ func validate(iface interface{}) error {
 
 		if tag == "" {
 			if IsStructReally(fTyp.Type) {
-				err := validate(fVal.Interface())
+				err := validate(conf, fVal.Interface())
 				if err != nil {
 					return err
 				}
 			}
 			continue
 		}
-		err := validateField(tag, fVal, fTyp)
+		err := validateField(conf, tag, fVal, fTyp)
 		if err != nil {
 			return err
 		}